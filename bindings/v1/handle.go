@@ -93,10 +93,25 @@ func handler(tfStream internal.ThreadSafeStream[proto.ReadResponse, proto.ReadRe
 }
 
 // streamReader creates a message handler for the given stream.
+// the handler stops handing new messages when the server is shutting down, and the returned ack loop
+// keeps receiving acks until all pending ones are settled, the component should be canceled once it returns.
 func streamReader(stream proto.InputBinding_ReadServer) (bindingsHandler contribBindings.Handler, acknLoop func() error) {
 	tfStream := internal.NewGRPCThreadSafeStream[proto.ReadResponse, proto.ReadRequest](stream)
 	ackManager := internal.NewAckManager[*handleResponse]()
-	return handler(tfStream, ackManager), func() error {
-		return ackLoop(stream.Context(), tfStream, ackManager)
+	shutdown := internal.ShutdownFromContext(stream.Context())
+	handle := handler(tfStream, ackManager)
+	bindingsHandler = func(ctx context.Context, msg *contribBindings.ReadResponse) (data []byte, err error) {
+		defer internal.Recover(stream.Context(), inputLogger, &err)
+		if internal.IsShuttingDown(shutdown) {
+			return nil, internal.ErrShuttingDown
+		}
+		return handle(ctx, msg)
+	}
+	acknLoop = func() error {
+		return internal.DrainAcks(shutdown, func() (err error) {
+			defer internal.Recover(stream.Context(), inputLogger, &err)
+			return ackLoop(stream.Context(), tfStream, ackManager)
+		}, ackManager)
 	}
+	return bindingsHandler, acknLoop
 }
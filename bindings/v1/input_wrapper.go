@@ -22,6 +22,9 @@ import (
 
 	"github.com/dapr/kit/logger"
 
+	sdkerrors "github.com/dapr-sandbox/components-go-sdk/errors"
+	"github.com/dapr-sandbox/components-go-sdk/internal"
+
 	"google.golang.org/grpc"
 )
 
@@ -29,15 +32,19 @@ var inputLogger = logger.NewLogger("inputbinding-component")
 
 type inputBinding struct {
 	proto.UnimplementedInputBindingServer
-	getInstance func(context.Context) InputBinding
+	getInstance func(context.Context) (InputBinding, error)
 }
 
 func (in *inputBinding) Init(ctx context.Context, req *proto.InputBindingInitRequest) (*proto.InputBindingInitResponse, error) {
-	return &proto.InputBindingInitResponse{}, in.getInstance(ctx).Init(ctx, bindings.Metadata{
+	instance, err := in.getInstance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.InputBindingInitResponse{}, sdkerrors.ToGRPC(instance.Init(ctx, bindings.Metadata{
 		Base: metadata.Base{
 			Properties: req.Metadata.Properties,
 		},
-	})
+	}))
 }
 
 func (in *inputBinding) Read(stream proto.InputBinding_ReadServer) error {
@@ -46,20 +53,36 @@ func (in *inputBinding) Read(stream proto.InputBinding_ReadServer) error {
 
 	handler, startAckLoop := streamReader(stream)
 
-	err := in.getInstance(ctx).Read(ctx, handler)
+	instance, err := in.getInstance(ctx)
 	if err != nil {
 		return err
 	}
 
+	err = instance.Read(ctx, handler)
+	if err != nil {
+		return sdkerrors.ToGRPC(err)
+	}
+
 	return startAckLoop()
 }
 
-func (in *inputBinding) Ping(context.Context, *proto.PingRequest) (*proto.PingResponse, error) {
-	return &proto.PingResponse{}, nil
+func (in *inputBinding) Ping(ctx context.Context, _ *proto.PingRequest) (*proto.PingResponse, error) {
+	instance, err := in.getInstance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.PingResponse{}, sdkerrors.ToGRPC(internal.Ping(ctx, instance))
 }
 
 // RegisterInput the inputbinding implementation for the component gRPC service.
 func RegisterInput(server *grpc.Server, getInstance func(context.Context) InputBinding) {
+	RegisterInputInstances(server, func(ctx context.Context) (InputBinding, error) {
+		return getInstance(ctx), nil
+	})
+}
+
+// RegisterInputInstances is like RegisterInput but gets the binding of each call from getInstance.
+func RegisterInputInstances(server *grpc.Server, getInstance func(context.Context) (InputBinding, error)) {
 	inputBinding := &inputBinding{
 		getInstance: getInstance,
 	}
@@ -16,6 +16,7 @@ package bindings
 import (
 	"context"
 
+	sdkerrors "github.com/dapr-sandbox/components-go-sdk/errors"
 	"github.com/dapr-sandbox/components-go-sdk/internal"
 	"google.golang.org/grpc"
 
@@ -26,25 +27,33 @@ import (
 
 type outputBinding struct {
 	proto.UnimplementedOutputBindingServer
-	getInstance func(context.Context) OutputBinding
+	getInstance func(context.Context) (OutputBinding, error)
 }
 
 func (out *outputBinding) Init(ctx context.Context, req *proto.OutputBindingInitRequest) (*proto.OutputBindingInitResponse, error) {
-	return &proto.OutputBindingInitResponse{}, out.getInstance(ctx).Init(ctx, contribBindings.Metadata{
+	instance, err := out.getInstance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.OutputBindingInitResponse{}, sdkerrors.ToGRPC(instance.Init(ctx, contribBindings.Metadata{
 		Base: metadata.Base{
 			Properties: req.Metadata.Properties,
 		},
-	})
+	}))
 }
 
 func (out *outputBinding) Invoke(ctx context.Context, req *proto.InvokeRequest) (*proto.InvokeResponse, error) {
-	resp, err := out.getInstance(ctx).Invoke(ctx, &contribBindings.InvokeRequest{
+	instance, err := out.getInstance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := instance.Invoke(ctx, &contribBindings.InvokeRequest{
 		Data:      req.Data,
 		Metadata:  req.Metadata,
 		Operation: contribBindings.OperationKind(req.Operation),
 	})
 	if err != nil {
-		return nil, err
+		return nil, sdkerrors.ToGRPC(err)
 	}
 	if resp == nil {
 		return &proto.InvokeResponse{}, nil
@@ -57,19 +66,34 @@ func (out *outputBinding) Invoke(ctx context.Context, req *proto.InvokeRequest)
 }
 
 func (out *outputBinding) ListOperations(ctx context.Context, _ *proto.ListOperationsRequest) (*proto.ListOperationsResponse, error) {
+	instance, err := out.getInstance(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return &proto.ListOperationsResponse{
-		Operations: internal.Map(out.getInstance(ctx).Operations(), func(op contribBindings.OperationKind) string {
+		Operations: internal.Map(instance.Operations(), func(op contribBindings.OperationKind) string {
 			return string(op)
 		}),
 	}, nil
 }
 
-func (out *outputBinding) Ping(context.Context, *proto.PingRequest) (*proto.PingResponse, error) {
-	return &proto.PingResponse{}, nil
+func (out *outputBinding) Ping(ctx context.Context, _ *proto.PingRequest) (*proto.PingResponse, error) {
+	instance, err := out.getInstance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.PingResponse{}, sdkerrors.ToGRPC(internal.Ping(ctx, instance))
 }
 
 // RegisterOutput the outputbinding implementation for the component gRPC service.
 func RegisterOutput(server *grpc.Server, getInstance func(context.Context) OutputBinding) {
+	RegisterOutputInstances(server, func(ctx context.Context) (OutputBinding, error) {
+		return getInstance(ctx), nil
+	})
+}
+
+// RegisterOutputInstances is like RegisterOutput but gets the binding of each call from getInstance.
+func RegisterOutputInstances(server *grpc.Server, getInstance func(context.Context) (OutputBinding, error)) {
 	outputBinding := &outputBinding{
 		getInstance: getInstance,
 	}
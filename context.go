@@ -0,0 +1,64 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dapr
+
+import (
+	"context"
+
+	"github.com/dapr-sandbox/components-go-sdk/internal"
+
+	"google.golang.org/grpc"
+)
+
+// InstanceIDFromContext returns the ID of the component instance the call belongs to,
+// as sent by daprd using the `x-component-instance` metadata header. It is empty when daprd does not send it.
+// The ID is carried by every context the SDK passes to the component, including the streaming ones.
+func InstanceIDFromContext(ctx context.Context) string {
+	return internal.InstanceIDFromContext(ctx)
+}
+
+// ComponentNameFromContext returns the name the component was registered with, which is also the socket name.
+func ComponentNameFromContext(ctx context.Context) string {
+	return internal.ComponentNameFromContext(ctx)
+}
+
+// visibleInstanceID returns the instance ID as seen by the components, the default instance has none.
+func visibleInstanceID(instanceID string) string {
+	if instanceID == defaultInstanceID {
+		return ""
+	}
+	return instanceID
+}
+
+// withInstanceFromIncomingContext adds the component instance ID and the registration name to the context.
+func withInstanceFromIncomingContext(ctx context.Context, name string) context.Context {
+	return internal.WithInstance(ctx, visibleInstanceID(instanceIDFromIncomingContext(ctx)), name)
+}
+
+// instanceUnaryInterceptor adds the component instance ID and the registration name to the calls context.
+func instanceUnaryInterceptor(name string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(withInstanceFromIncomingContext(ctx, name), req)
+	}
+}
+
+// instanceStreamInterceptor adds the component instance ID and the registration name to the streams context.
+func instanceStreamInterceptor(name string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{
+			ServerStream: ss,
+			ctx:          withInstanceFromIncomingContext(ss.Context(), name),
+		})
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dapr
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	contribState "github.com/dapr/components-contrib/state"
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+
+	"github.com/dapr-sandbox/components-go-sdk/state/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type fakeContextStateStore struct {
+	state.Store
+	initCtx context.Context
+}
+
+func (f *fakeContextStateStore) Init(ctx context.Context, _ contribState.Metadata) error {
+	f.initCtx = ctx
+	return nil
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (f *fakeServerStream) Context() context.Context {
+	return f.ctx
+}
+
+func TestContext(t *testing.T) {
+	t.Run("component calls context should carry the instance ID and the component name", func(t *testing.T) {
+		t.Parallel()
+		const fakeComponent = "fake-context"
+		socketFolder := socketTempDir(t)
+		store := &fakeContextStateStore{}
+		server := NewServer(WithSocketFolder(socketFolder))
+		server.Register(fakeComponent, WithStateStore(func() state.Store { return store }))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go server.Serve(ctx) //nolint:errcheck
+
+		socket := filepath.Join(socketFolder, fakeComponent+".sock")
+		waitForSocket(t, socket)
+		client := proto.NewStateStoreClient(dialSocket(t, socket))
+
+		_, err := client.Init(metadata.AppendToOutgoingContext(ctx, metadataInstanceID, "my-instance"), &proto.InitRequest{Metadata: &proto.MetadataRequest{}})
+		require.NoError(t, err)
+		assert.Equal(t, "my-instance", InstanceIDFromContext(store.initCtx))
+		assert.Equal(t, fakeComponent, ComponentNameFromContext(store.initCtx))
+	})
+
+	t.Run("streams context should carry the instance ID and the component name", func(t *testing.T) {
+		ss := &fakeServerStream{ctx: instanceCtx("my-instance")}
+		var streamCtx context.Context
+		err := instanceStreamInterceptor("my-component")(nil, ss, &grpc.StreamServerInfo{}, func(_ any, stream grpc.ServerStream) error {
+			streamCtx = stream.Context()
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "my-instance", InstanceIDFromContext(streamCtx))
+		assert.Equal(t, "my-component", ComponentNameFromContext(streamCtx))
+	})
+
+	t.Run("instance ID should be empty for the default instance", func(t *testing.T) {
+		var callCtx context.Context
+		_, err := instanceUnaryInterceptor("my-component")(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ any) (any, error) {
+			callCtx = ctx
+			return nil, nil
+		})
+		require.NoError(t, err)
+		assert.Empty(t, InstanceIDFromContext(callCtx))
+		assert.Equal(t, "my-component", ComponentNameFromContext(callCtx))
+	})
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package errors provides typed errors that components can return to let daprd know what went wrong.
+// The errors are translated into gRPC status codes carrying structured details, so daprd can tell
+// a transient failure, which is worth retrying, from a bad request.
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Domain is the error info domain of the errors.
+const Domain = "dapr.io"
+
+// reasons are the error info reasons of each error code.
+var reasons = map[codes.Code]string{
+	codes.NotFound:          "NOT_FOUND",
+	codes.AlreadyExists:     "ALREADY_EXISTS",
+	codes.Aborted:           "CONFLICT",
+	codes.InvalidArgument:   "INVALID_ARGUMENT",
+	codes.Unavailable:       "UNAVAILABLE",
+	codes.Unauthenticated:   "UNAUTHENTICATED",
+	codes.ResourceExhausted: "RESOURCE_EXHAUSTED",
+}
+
+// Error is a component error mapped to a gRPC status code.
+type Error struct {
+	code       codes.Code
+	err        error
+	metadata   map[string]string
+	retryDelay time.Duration
+}
+
+func newError(code codes.Code, format string, args ...any) *Error {
+	return &Error{
+		code: code,
+		err:  fmt.Errorf(format, args...),
+	}
+}
+
+// NotFound returns an error indicating that the requested resource does not exist.
+func NotFound(format string, args ...any) *Error {
+	return newError(codes.NotFound, format, args...)
+}
+
+// AlreadyExists returns an error indicating that the resource being created already exists.
+func AlreadyExists(format string, args ...any) *Error {
+	return newError(codes.AlreadyExists, format, args...)
+}
+
+// Conflict returns an error indicating that the operation conflicts with a concurrent one.
+func Conflict(format string, args ...any) *Error {
+	return newError(codes.Aborted, format, args...)
+}
+
+// InvalidArgument returns an error indicating that the request is invalid and should not be retried as is.
+func InvalidArgument(format string, args ...any) *Error {
+	return newError(codes.InvalidArgument, format, args...)
+}
+
+// Unavailable returns an error indicating a transient failure, the operation can be retried.
+func Unavailable(format string, args ...any) *Error {
+	return newError(codes.Unavailable, format, args...)
+}
+
+// Unauthenticated returns an error indicating that the component could not authenticate against its backing service.
+func Unauthenticated(format string, args ...any) *Error {
+	return newError(codes.Unauthenticated, format, args...)
+}
+
+// ResourceExhausted returns an error indicating that a quota or rate limit was reached, the operation can be retried later.
+func ResourceExhausted(format string, args ...any) *Error {
+	return newError(codes.ResourceExhausted, format, args...)
+}
+
+// WithMetadata returns a copy of the error carrying the given metadata on its error info details.
+func (e *Error) WithMetadata(key, value string) *Error {
+	cp := *e
+	cp.metadata = make(map[string]string, len(e.metadata)+1)
+	for k, v := range e.metadata {
+		cp.metadata[k] = v
+	}
+	cp.metadata[key] = value
+	return &cp
+}
+
+// WithRetryDelay returns a copy of the error advising to wait the given delay before retrying.
+func (e *Error) WithRetryDelay(delay time.Duration) *Error {
+	cp := *e
+	cp.retryDelay = delay
+	return &cp
+}
+
+// Code returns the gRPC code of the error.
+func (e *Error) Code() codes.Code {
+	return e.code
+}
+
+// Retryable returns true if the operation can be retried.
+func (e *Error) Retryable() bool {
+	return e.code == codes.Unavailable || e.code == codes.ResourceExhausted
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// GRPCStatus returns the gRPC status of the error along with its error info and retry info details.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(e.code, e.Error())
+	errorInfo := &errdetails.ErrorInfo{
+		Reason:   reasons[e.code],
+		Domain:   Domain,
+		Metadata: e.metadata,
+	}
+
+	var (
+		withDetails *status.Status
+		err         error
+	)
+	if e.retryDelay > 0 {
+		withDetails, err = st.WithDetails(errorInfo, &errdetails.RetryInfo{RetryDelay: durationpb.New(e.retryDelay)})
+	} else {
+		withDetails, err = st.WithDetails(errorInfo)
+	}
+	if err != nil {
+		return st
+	}
+	return withDetails
+}
+
+// IsRetryable returns true if the error, or any error it wraps, is an Error that can be retried.
+func IsRetryable(err error) bool {
+	var sdkErr *Error
+	return errors.As(err, &sdkErr) && sdkErr.Retryable()
+}
+
+// ToGRPC translates the error into a gRPC status error when it is, or wraps, an Error.
+// other errors are returned as is.
+func ToGRPC(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+		return err
+	}
+	var sdkErr *Error
+	if errors.As(err, &sdkErr) {
+		// keep the message of the wrapping error since it usually adds context to the original one.
+		st := sdkErr.GRPCStatus().Proto()
+		st.Message = err.Error()
+		return status.ErrorProto(st)
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrors(t *testing.T) {
+	t.Run("errors should be mapped to their gRPC codes", func(t *testing.T) {
+		for expected, err := range map[codes.Code]error{
+			codes.NotFound:          NotFound("key %s", "a"),
+			codes.AlreadyExists:     AlreadyExists("key %s", "a"),
+			codes.Aborted:           Conflict("key %s", "a"),
+			codes.InvalidArgument:   InvalidArgument("key %s", "a"),
+			codes.Unavailable:       Unavailable("key %s", "a"),
+			codes.Unauthenticated:   Unauthenticated("key %s", "a"),
+			codes.ResourceExhausted: ResourceExhausted("key %s", "a"),
+		} {
+			st, ok := status.FromError(err)
+			require.True(t, ok)
+			assert.Equal(t, expected, st.Code())
+			assert.Equal(t, "key a", st.Message())
+		}
+	})
+
+	t.Run("status should carry the error info and retry info details", func(t *testing.T) {
+		err := Unavailable("broker is down").WithMetadata("broker", "b1").WithRetryDelay(time.Second)
+		details := err.GRPCStatus().Details()
+		require.Len(t, details, 2)
+
+		errorInfo, ok := details[0].(*errdetails.ErrorInfo)
+		require.True(t, ok)
+		assert.Equal(t, "UNAVAILABLE", errorInfo.Reason)
+		assert.Equal(t, Domain, errorInfo.Domain)
+		assert.Equal(t, map[string]string{"broker": "b1"}, errorInfo.Metadata)
+
+		retryInfo, ok := details[1].(*errdetails.RetryInfo)
+		require.True(t, ok)
+		assert.Equal(t, time.Second, retryInfo.RetryDelay.AsDuration())
+	})
+
+	t.Run("with metadata should not modify the original error", func(t *testing.T) {
+		original := NotFound("not found")
+		original.WithMetadata("a", "b")
+		assert.Empty(t, original.metadata)
+	})
+
+	t.Run("only unavailable and resource exhausted errors should be retryable", func(t *testing.T) {
+		assert.True(t, IsRetryable(Unavailable("down")))
+		assert.True(t, IsRetryable(fmt.Errorf("wrapped: %w", ResourceExhausted("quota"))))
+		assert.False(t, IsRetryable(InvalidArgument("bad")))
+		assert.False(t, IsRetryable(errors.New("unknown")))
+	})
+
+	t.Run("to gRPC should translate wrapped errors keeping the wrapping message", func(t *testing.T) {
+		err := ToGRPC(fmt.Errorf("could not publish: %w", Unavailable("broker is down")))
+		st, ok := status.FromError(err)
+		require.True(t, ok)
+		assert.Equal(t, codes.Unavailable, st.Code())
+		assert.Equal(t, "could not publish: broker is down", st.Message())
+		assert.Len(t, st.Details(), 1)
+	})
+
+	t.Run("to gRPC should return other errors as is", func(t *testing.T) {
+		fakeErr := errors.New("fake-err")
+		assert.Nil(t, ToGRPC(nil))
+		assert.Equal(t, fakeErr, ToGRPC(fakeErr))
+	})
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dapr
+
+import (
+	"context"
+	"strings"
+
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const componentsServicePrefix = "dapr.proto.components.v1."
+
+// relatedServices are the services that share the status of the service that owns the Init and Ping methods.
+var relatedServices = map[string][]string{
+	proto.StateStore_ServiceDesc.ServiceName: {
+		proto.TransactionalStateStore_ServiceDesc.ServiceName,
+		proto.QueriableStateStore_ServiceDesc.ServiceName,
+	},
+}
+
+// registerHealth registers the given health server as the standard gRPC health service
+// and marks all component services as serving.
+func registerHealth(server *grpc.Server, healthServer *health.Server) {
+	for service := range server.GetServiceInfo() {
+		if strings.HasPrefix(service, componentsServicePrefix) {
+			healthServer.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+		}
+	}
+	healthpb.RegisterHealthServer(server, healthServer)
+}
+
+// splitMethod splits a full method name (/package.service/method) into service and method.
+func splitMethod(fullMethod string) (service string, method string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if idx := strings.LastIndex(fullMethod, "/"); idx >= 0 {
+		return fullMethod[:idx], fullMethod[idx+1:]
+	}
+	return "", fullMethod
+}
+
+// healthUnaryInterceptor updates the service health status based on the Init and Ping results.
+func healthUnaryInterceptor(healthServer *health.Server) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+
+		service, method := splitMethod(info.FullMethod)
+		if method != "Init" && method != "Ping" {
+			return resp, err
+		}
+
+		status := healthpb.HealthCheckResponse_SERVING
+		if err != nil {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+
+		setServingStatus(healthServer, service, status)
+		return resp, err
+	}
+}
+
+// setServingStatus sets the status of the given service along with the status of the services that share it.
+func setServingStatus(healthServer *health.Server, service string, status healthpb.HealthCheckResponse_ServingStatus) {
+	service = ownerService(service)
+	healthServer.SetServingStatus(service, status)
+	for _, related := range relatedServices[service] {
+		healthServer.SetServingStatus(related, status)
+	}
+}
+
+// ownerService returns the service that owns the Init and Ping methods of the given service.
+func ownerService(service string) string {
+	for owner, related := range relatedServices {
+		for _, relatedService := range related {
+			if relatedService == service {
+				return owner
+			}
+		}
+	}
+	return service
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dapr
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	contribState "github.com/dapr/components-contrib/state"
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+
+	"github.com/dapr-sandbox/components-go-sdk/state/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type fakeInitStateStore struct {
+	state.Store
+	initErr error
+}
+
+func (f *fakeInitStateStore) Init(context.Context, contribState.Metadata) error {
+	return f.initErr
+}
+
+func TestHealth(t *testing.T) {
+	t.Run("split method should return service and method names", func(t *testing.T) {
+		service, method := splitMethod("/dapr.proto.components.v1.StateStore/Init")
+		assert.Equal(t, "dapr.proto.components.v1.StateStore", service)
+		assert.Equal(t, "Init", method)
+	})
+
+	t.Run("health status should follow the component init result", func(t *testing.T) {
+		t.Parallel()
+		const fakeComponent = "fake-health"
+		socketFolder := socketTempDir(t)
+		store := &fakeInitStateStore{initErr: errors.New("fake-init-err")}
+		server := NewServer(WithSocketFolder(socketFolder))
+		server.Register(fakeComponent, WithStateStore(func() state.Store { return store }))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go server.Serve(ctx) //nolint:errcheck
+
+		socket := filepath.Join(socketFolder, fakeComponent+".sock")
+		waitForSocket(t, socket)
+		conn := dialSocket(t, socket)
+		healthClient := healthpb.NewHealthClient(conn)
+		checkStatus := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+			resp, err := healthClient.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+			require.NoError(t, err)
+			return resp.Status
+		}
+
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, checkStatus(proto.StateStore_ServiceDesc.ServiceName))
+
+		_, err := proto.NewStateStoreClient(conn).Init(ctx, &proto.InitRequest{Metadata: &proto.MetadataRequest{}})
+		require.Error(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, checkStatus(proto.StateStore_ServiceDesc.ServiceName))
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, checkStatus(proto.TransactionalStateStore_ServiceDesc.ServiceName))
+
+		store.initErr = nil
+		_, err = proto.NewStateStoreClient(conn).Init(ctx, &proto.InitRequest{Metadata: &proto.MetadataRequest{}})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, checkStatus(proto.StateStore_ServiceDesc.ServiceName))
+	})
+}
@@ -14,31 +14,58 @@ limitations under the License.
 package internal
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// maxExpiredAcks is the number of expired messages remembered to recognize their late acks.
+const maxExpiredAcks = 1024
+
+// ErrLateAck is returned when acknowledging a message whose deadline has expired.
+var ErrLateAck = errors.New("message was acked after its deadline")
+
 // AcknowledgementManager control the messages acknowledgement from the server.
 type AcknowledgementManager[TAckResult any] struct {
 	pendingAcks    map[string]chan TAckResult
 	mu             *sync.RWMutex
 	ackTimeoutFunc func() <-chan time.Time
+	// drained is closed when the last pending ack is cleaned up.
+	drained chan struct{}
+	// expired holds the most recently expired messages, in expiredOrder.
+	expired      map[string]struct{}
+	expiredOrder []string
+	lateAcks     atomic.Int64
+	// slots bounds the messages acquired through Acquire, nil when unbounded.
+	slots chan struct{}
 }
 
 func NewAckManager[TAckResult any]() *AcknowledgementManager[TAckResult] {
-	return &AcknowledgementManager[TAckResult]{
+	return NewBoundedAckManager[TAckResult](0)
+}
+
+// NewBoundedAckManager returns an ack manager whose Acquire waits while maxPending messages are pending,
+// zero or less is unbounded.
+func NewBoundedAckManager[TAckResult any](maxPending int) *AcknowledgementManager[TAckResult] {
+	m := &AcknowledgementManager[TAckResult]{
 		pendingAcks: map[string]chan TAckResult{},
 		mu:          &sync.RWMutex{},
 		ackTimeoutFunc: func() <-chan time.Time {
 			return time.After(time.Second)
 		},
 	}
+	if maxPending > 0 {
+		m.slots = make(chan struct{}, maxPending)
+	}
+	return m
 }
 
-// Pending returns a copy of pending acks list
+// Pending returns a copy of pending acks list, its length is the current in-flight depth.
 func (m *AcknowledgementManager[TAckResult]) Pending() []chan TAckResult {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -57,6 +84,9 @@ func (m *AcknowledgementManager[TAckResult]) Get() (messageID string, ackChan ch
 	defer m.mu.Unlock()
 	msgID := uuid.New().String()
 
+	if len(m.pendingAcks) == 0 {
+		m.drained = make(chan struct{})
+	}
 	ackChan = make(chan TAckResult, 1)
 	m.pendingAcks[msgID] = ackChan
 
@@ -64,29 +94,103 @@ func (m *AcknowledgementManager[TAckResult]) Get() (messageID string, ackChan ch
 		m.mu.Lock()
 		delete(m.pendingAcks, msgID)
 		close(ackChan)
+		if len(m.pendingAcks) == 0 && m.drained != nil {
+			close(m.drained)
+			m.drained = nil
+		}
 		m.mu.Unlock()
 	}
 }
 
+// Acquire is like Get but waits for a free slot when the manager is bounded,
+// the slot is released on cleanup. it returns the context error when the context is done first.
+func (m *AcknowledgementManager[TAckResult]) Acquire(ctx context.Context) (messageID string, ackChan chan TAckResult, cleanup func(), err error) {
+	if m.slots == nil {
+		messageID, ackChan, cleanup = m.Get()
+		return messageID, ackChan, cleanup, nil
+	}
+	select {
+	case m.slots <- struct{}{}:
+	case <-ctx.Done():
+		return "", nil, nil, ctx.Err()
+	}
+	messageID, ackChan, release := m.Get()
+	return messageID, ackChan, func() {
+		release()
+		<-m.slots
+	}, nil
+}
+
+// Expire marks the given pending message as expired, so its ack is reported as late.
+// it should be called before cleaning up the message.
+func (m *AcknowledgementManager[TAckResult]) Expire(messageID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.expired == nil {
+		m.expired = make(map[string]struct{})
+	}
+	if len(m.expiredOrder) >= maxExpiredAcks {
+		delete(m.expired, m.expiredOrder[0])
+		m.expiredOrder = m.expiredOrder[1:]
+	}
+	m.expired[messageID] = struct{}{}
+	m.expiredOrder = append(m.expiredOrder, messageID)
+}
+
+// LateAcks returns the number of acks received after the deadline of their message.
+func (m *AcknowledgementManager[TAckResult]) LateAcks() int64 {
+	return m.lateAcks.Load()
+}
+
+// Drained returns a channel that is closed when there are no pending acks left.
+func (m *AcknowledgementManager[TAckResult]) Drained() <-chan struct{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.pendingAcks) == 0 {
+		drained := make(chan struct{})
+		close(drained)
+		return drained
+	}
+	return m.drained
+}
+
 // Ack acknowledge a message
 func (m *AcknowledgementManager[TAckResult]) Ack(messageID string, result TAckResult) error {
+	sent, err := m.trySend(messageID, result)
+	if errors.Is(err, ErrLateAck) {
+		m.lateAcks.Add(1)
+	}
+	if sent || err != nil {
+		return err
+	}
+
+	// the channel is bufferized size 1, so it is only full when the previous ack was not consumed yet.
+	// if no consumer takes it before the ackTimeoutFunc (defaults to 1s) it probably means
+	// that no consumer is waiting for the message ack or it is a duplicated ack for the same message.
+	<-m.ackTimeoutFunc()
+	_, _ = m.trySend(messageID, result)
+	return nil
+}
+
+// trySend sends the result to the message channel without blocking, it reports whether the result was sent.
+// the lock is held during the send so the channel can't be closed meanwhile, it is never held while waiting.
+func (m *AcknowledgementManager[TAckResult]) trySend(messageID string, result TAckResult) (bool, error) {
 	m.mu.RLock()
-	c, ok := m.pendingAcks[messageID]
-	m.mu.RUnlock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.expired[messageID]; ok {
+		return false, ErrLateAck
+	}
 
+	c, ok := m.pendingAcks[messageID]
 	if !ok {
-		return fmt.Errorf("message %s not found or not specified", messageID)
+		return false, fmt.Errorf("message %s not found or not specified", messageID)
 	}
 
 	select {
-	// wait time for outstanding acks
-	// that should be instantaneous as the channel is bufferized size 1
-	// if this operation takes longer than the waitFunc (defaults to 1s)
-	// it probably means that no consumer is waiting for the message ack
-	// or it could be duplicated ack for the same message.
-	case <-m.ackTimeoutFunc():
-		return nil
 	case c <- result:
-		return nil
+		return true, nil
+	default:
+		return false, nil
 	}
 }
@@ -14,6 +14,8 @@ limitations under the License.
 package internal
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -83,4 +85,107 @@ func TestAckManager(t *testing.T) {
 		_ = manager.Ack(fakeMessageID, nil)
 		assert.Len(t, c, 0)
 	})
+	t.Run("waiting for a duplicated ack should not block other messages", func(t *testing.T) {
+		const fakeMessageID = "fakeMessageID"
+		timeout := make(chan time.Time)
+		manager := NewAckManager[error]()
+		manager.pendingAcks[fakeMessageID] = make(chan error)
+		manager.ackTimeoutFunc = func() <-chan time.Time {
+			return timeout
+		}
+
+		acked := make(chan error, 1)
+		go func() {
+			acked <- manager.Ack(fakeMessageID, nil)
+		}()
+
+		got := make(chan struct{})
+		go func() {
+			_, _, cleanup := manager.Get()
+			cleanup()
+			close(got)
+		}()
+		select {
+		case <-got:
+		case <-time.After(time.Second):
+			t.Fatal("get should not wait for the ack timeout")
+		}
+
+		close(timeout)
+		assert.NoError(t, <-acked)
+	})
+	t.Run("drained should be closed when there are no pending acks", func(t *testing.T) {
+		manager := NewAckManager[error]()
+		select {
+		case <-manager.Drained():
+		default:
+			t.Fatal("drained channel should be closed")
+		}
+	})
+	t.Run("drained should be closed after the last pending ack is cleaned up", func(t *testing.T) {
+		manager := NewAckManager[error]()
+		_, _, cleanup1 := manager.Get()
+		_, _, cleanup2 := manager.Get()
+		drained := manager.Drained()
+		cleanup1()
+		select {
+		case <-drained:
+			t.Fatal("drained channel should not be closed while acks are pending")
+		default:
+		}
+		cleanup2()
+		select {
+		case <-drained:
+		case <-time.After(time.Second):
+			t.Fatal("drained channel should be closed")
+		}
+	})
+	t.Run("ack-ing an expired message should return a late ack error and count it", func(t *testing.T) {
+		manager := NewAckManager[error]()
+		msgID, _, cleanup := manager.Get()
+		manager.Expire(msgID)
+		cleanup()
+		assert.ErrorIs(t, manager.Ack(msgID, nil), ErrLateAck)
+		assert.Equal(t, int64(1), manager.LateAcks())
+		assert.NotErrorIs(t, manager.Ack("fake-id", nil), ErrLateAck)
+	})
+	t.Run("only the most recently expired messages should be remembered", func(t *testing.T) {
+		manager := NewAckManager[error]()
+		manager.Expire("first")
+		for i := 0; i < maxExpiredAcks; i++ {
+			manager.Expire(fmt.Sprint(i))
+		}
+		assert.Len(t, manager.expired, maxExpiredAcks)
+		assert.NotErrorIs(t, manager.Ack("first", nil), ErrLateAck)
+	})
+	t.Run("acquire should wait for a free slot when the manager is bounded", func(t *testing.T) {
+		manager := NewBoundedAckManager[error](1)
+		_, _, cleanup, err := manager.Acquire(context.Background())
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, _, _, err = manager.Acquire(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Len(t, manager.Pending(), 1)
+
+		acquired := make(chan struct{})
+		go func() {
+			_, _, cleanup, err := manager.Acquire(context.Background())
+			assert.NoError(t, err)
+			close(acquired)
+			cleanup()
+		}()
+		select {
+		case <-acquired:
+			t.Fatal("acquire should wait while the manager is full")
+		case <-time.After(10 * time.Millisecond):
+		}
+		cleanup()
+		select {
+		case <-acquired:
+		case <-time.After(time.Second):
+			t.Fatal("acquire should return once a slot is released")
+		}
+	})
 }
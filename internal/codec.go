@@ -0,0 +1,93 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"encoding/json"
+	"mime"
+	"strings"
+	"sync"
+)
+
+// Codec encodes and decodes the values of a content type.
+type Codec interface {
+	// Marshal encodes the given value.
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes the given data.
+	Unmarshal(data []byte) (any, error)
+}
+
+// JSONCodec is the codec of the `application/json` content type.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte) (any, error) {
+	var result any
+	return result, json.Unmarshal(data, &result)
+}
+
+// CodecRegistry holds the codecs of each content type.
+type CodecRegistry struct {
+	mu     sync.RWMutex
+	codecs map[string]Codec
+}
+
+// NewCodecRegistry creates a new codec registry with the json codec registered.
+func NewCodecRegistry() *CodecRegistry {
+	return &CodecRegistry{
+		codecs: map[string]Codec{
+			"application/json": JSONCodec{},
+		},
+	}
+}
+
+// Codecs is the codec registry shared by all components.
+var Codecs = NewCodecRegistry()
+
+// Register registers the codec for the given content type, replacing the previous one if any.
+func (r *CodecRegistry) Register(contentType string, codec Codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codecs[mediaType(contentType)] = codec
+}
+
+// Lookup returns the codec for the given content type. Parameters such as the charset are ignored,
+// and content types with a structured syntax suffix (e.g. application/cloudevents+json) fallback to
+// the codec of the suffix (e.g. application/json) when they have no codec of their own.
+func (r *CodecRegistry) Lookup(contentType string) (Codec, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	mt := mediaType(contentType)
+	if codec, ok := r.codecs[mt]; ok {
+		return codec, true
+	}
+	if idx := strings.LastIndex(mt, "+"); idx >= 0 {
+		codec, ok := r.codecs["application/"+mt[idx+1:]]
+		return codec, ok
+	}
+	return nil, false
+}
+
+// mediaType returns the lowercase media type of the content type without its parameters.
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mt
+}
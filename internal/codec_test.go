@@ -0,0 +1,61 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCodec struct {
+	JSONCodec
+}
+
+func TestCodecRegistry(t *testing.T) {
+	t.Run("json codec should be registered by default", func(t *testing.T) {
+		codec, ok := NewCodecRegistry().Lookup("application/json")
+		require.True(t, ok)
+		v, err := codec.Unmarshal([]byte(`{"a":1}`))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"a": float64(1)}, v)
+	})
+
+	t.Run("lookup should ignore the content type parameters and case", func(t *testing.T) {
+		_, ok := NewCodecRegistry().Lookup("Application/JSON; charset=utf-8")
+		assert.True(t, ok)
+	})
+
+	t.Run("lookup should fallback to the suffix codec", func(t *testing.T) {
+		codec, ok := NewCodecRegistry().Lookup("application/cloudevents+json")
+		require.True(t, ok)
+		assert.Equal(t, JSONCodec{}, codec)
+	})
+
+	t.Run("registered codecs should take precedence over the suffix codec", func(t *testing.T) {
+		registry := NewCodecRegistry()
+		registry.Register("application/cloudevents+json", fakeCodec{})
+		codec, ok := registry.Lookup("application/cloudevents+json")
+		require.True(t, ok)
+		assert.Equal(t, fakeCodec{}, codec)
+	})
+
+	t.Run("lookup should return false for unknown content types", func(t *testing.T) {
+		_, ok := NewCodecRegistry().Lookup("application/x-unknown")
+		assert.False(t, ok)
+		_, ok = NewCodecRegistry().Lookup("application/vnd+yaml")
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import "context"
+
+type instanceKey struct{}
+
+// instance identifies the component instance a call belongs to.
+type instance struct {
+	id   string
+	name string
+}
+
+// WithInstance returns a copy of the parent context carrying the given component instance ID and registration name.
+func WithInstance(ctx context.Context, instanceID, name string) context.Context {
+	return context.WithValue(ctx, instanceKey{}, instance{id: instanceID, name: name})
+}
+
+// InstanceIDFromContext returns the component instance ID carried by the context, or empty if absent.
+func InstanceIDFromContext(ctx context.Context) string {
+	inst, _ := ctx.Value(instanceKey{}).(instance)
+	return inst.id
+}
+
+// ComponentNameFromContext returns the component registration name carried by the context, or empty if absent.
+func ComponentNameFromContext(ctx context.Context) string {
+	inst, _ := ctx.Value(instanceKey{}).(instance)
+	return inst.name
+}
@@ -0,0 +1,34 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstance(t *testing.T) {
+	t.Run("instance ID and name should be empty when absent", func(t *testing.T) {
+		assert.Empty(t, InstanceIDFromContext(context.Background()))
+		assert.Empty(t, ComponentNameFromContext(context.Background()))
+	})
+
+	t.Run("instance ID and name should be returned when present", func(t *testing.T) {
+		ctx := WithInstance(context.Background(), "my-instance", "my-component")
+		assert.Equal(t, "my-instance", InstanceIDFromContext(ctx))
+		assert.Equal(t, "my-component", ComponentNameFromContext(ctx))
+	})
+}
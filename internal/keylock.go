@@ -0,0 +1,118 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// keyLock is the lock of a single key, it is held when its channel is full.
+type keyLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// KeyLocker locks keys independently, so operations on different keys don't wait for each other.
+type KeyLocker struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// NewKeyLocker creates a new key locker.
+func NewKeyLocker() *KeyLocker {
+	return &KeyLocker{
+		locks: make(map[string]*keyLock),
+	}
+}
+
+// Lock locks the given keys waiting until all of them are available or the context is done.
+// keys are always acquired in the same order so concurrent callers locking overlapping keys don't deadlock.
+func (l *KeyLocker) Lock(ctx context.Context, keys ...string) (unlock func(), err error) {
+	keys = uniqueSorted(keys)
+
+	acquired := make([]string, 0, len(keys))
+	unlockAcquired := func() {
+		for _, key := range acquired {
+			l.release(key)
+		}
+	}
+
+	for _, key := range keys {
+		lock := l.ref(key)
+		select {
+		case lock.ch <- struct{}{}:
+			acquired = append(acquired, key)
+		case <-ctx.Done():
+			l.unref(key)
+			unlockAcquired()
+			return nil, ctx.Err()
+		}
+	}
+
+	var once sync.Once
+	return func() { once.Do(unlockAcquired) }, nil
+}
+
+// ref returns the lock of the key, creating it if needed.
+func (l *KeyLocker) ref(key string) *keyLock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &keyLock{ch: make(chan struct{}, 1)}
+		l.locks[key] = lock
+	}
+	lock.refs++
+	return lock
+}
+
+// unref drops a reference to the key lock, removing it when it is no longer referenced.
+func (l *KeyLocker) unref(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock := l.locks[key]
+	lock.refs--
+	if lock.refs == 0 {
+		delete(l.locks, key)
+	}
+}
+
+// release unlocks the key and drops the reference acquired when locking it.
+func (l *KeyLocker) release(key string) {
+	l.mu.Lock()
+	lock := l.locks[key]
+	l.mu.Unlock()
+
+	<-lock.ch
+	l.unref(key)
+}
+
+// uniqueSorted returns the sorted unique keys.
+func uniqueSorted(keys []string) []string {
+	unique := make([]string, 0, len(keys))
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		unique = append(unique, key)
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyLocker(t *testing.T) {
+	t.Run("lock should wait until overlapping keys are unlocked", func(t *testing.T) {
+		locker := NewKeyLocker()
+		unlock, err := locker.Lock(context.Background(), "a", "b")
+		require.NoError(t, err)
+
+		locked := make(chan struct{})
+		go func() {
+			unlock, err := locker.Lock(context.Background(), "b", "c")
+			assert.NoError(t, err)
+			close(locked)
+			unlock()
+		}()
+
+		select {
+		case <-locked:
+			t.Fatal("overlapping keys should not be locked twice")
+		case <-time.After(50 * time.Millisecond):
+		}
+		unlock()
+		<-locked
+	})
+
+	t.Run("lock should not wait for other keys", func(t *testing.T) {
+		locker := NewKeyLocker()
+		unlock, err := locker.Lock(context.Background(), "a")
+		require.NoError(t, err)
+		defer unlock()
+
+		other, err := locker.Lock(context.Background(), "b", "b")
+		require.NoError(t, err)
+		other()
+	})
+
+	t.Run("lock should return the context error and release the acquired keys when it is done", func(t *testing.T) {
+		locker := NewKeyLocker()
+		unlock, err := locker.Lock(context.Background(), "b")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = locker.Lock(ctx, "a", "b")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		unlock()
+		assert.Empty(t, locker.locks)
+	})
+
+	t.Run("concurrent lockers of overlapping keys should not deadlock", func(t *testing.T) {
+		locker := NewKeyLocker()
+		var wg sync.WaitGroup
+		counter := 0
+		for i := 0; i < 50; i++ {
+			keys := []string{"a", "b"}
+			if i%2 == 0 {
+				keys = []string{"b", "a"}
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				unlock, err := locker.Lock(context.Background(), keys...)
+				assert.NoError(t, err)
+				counter++
+				unlock()
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 50, counter)
+		assert.Empty(t, locker.locks)
+	})
+}
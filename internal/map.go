@@ -23,6 +23,20 @@ func Map[From any, To any](from []From, mapper func(From) To) []To {
 	return res
 }
 
+// MapErr apply the given function to all list elements, stopping at the first error.
+func MapErr[From any, To any](from []From, mapper func(From) (To, error)) ([]To, error) {
+	res := make([]To, len(from))
+
+	for idx, value := range from {
+		mapped, err := mapper(value)
+		if err != nil {
+			return nil, err
+		}
+		res[idx] = mapped
+	}
+	return res, nil
+}
+
 // MapValues transform map values by applying mapper func.
 func MapValuesErr[From any, To any](from map[string]From, mapper func(From) (To, error)) (map[string]To, error) {
 	res := make(map[string]To, len(from))
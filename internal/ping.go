@@ -0,0 +1,29 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+
+	contribHealth "github.com/dapr/components-contrib/health"
+)
+
+// Ping delegates to the component when it implements the health.Pinger interface,
+// components that don't implement it are considered healthy.
+func Ping(ctx context.Context, component any) error {
+	if pinger, ok := component.(contribHealth.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
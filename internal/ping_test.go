@@ -0,0 +1,40 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePinger struct {
+	err error
+}
+
+func (f *fakePinger) Ping(context.Context) error {
+	return f.err
+}
+
+func TestPing(t *testing.T) {
+	t.Run("ping should return the component ping error", func(t *testing.T) {
+		err := errors.New("fake-err")
+		assert.Equal(t, err, Ping(context.Background(), &fakePinger{err: err}))
+	})
+	t.Run("components that don't implement pinger should be healthy", func(t *testing.T) {
+		assert.NoError(t, Ping(context.Background(), struct{}{}))
+	})
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"runtime/debug"
+
+	"github.com/dapr/kit/logger"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PanicError logs the recovered panic value along with its stack and the component instance carried by the context,
+// and returns it as an internal gRPC status error. It should be called with the result of recover.
+func PanicError(ctx context.Context, log logger.Logger, recovered any) error {
+	log.Errorf("recovered from panic on component %q instance %q: %v\n%s",
+		ComponentNameFromContext(ctx), InstanceIDFromContext(ctx), recovered, debug.Stack())
+	return status.Errorf(codes.Internal, "component panicked: %v", recovered)
+}
+
+// Recover recovers from a panic and sets err to its PanicError, it must be deferred by the function to recover.
+// it is used on the goroutines that the panic recovery of the gRPC server does not cover, such as the component ones.
+func Recover(ctx context.Context, log logger.Logger, err *error) {
+	if r := recover(); r != nil {
+		*err = PanicError(ctx, log, r)
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dapr/kit/logger"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPanicError(t *testing.T) {
+	t.Run("panic error should be an internal status error", func(t *testing.T) {
+		recovered := func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = PanicError(context.Background(), logger.NewLogger("test"), r)
+				}
+			}()
+			panic("fake-panic")
+		}()
+		assert.Equal(t, codes.Internal, status.Code(recovered))
+		assert.Contains(t, recovered.Error(), "fake-panic")
+	})
+}
+
+func TestRecover(t *testing.T) {
+	t.Run("recover should set the error to the panic error", func(t *testing.T) {
+		recovered := func() (err error) {
+			defer Recover(context.Background(), logger.NewLogger("test"), &err)
+			panic("fake-panic")
+		}()
+		assert.Equal(t, codes.Internal, status.Code(recovered))
+		assert.Contains(t, recovered.Error(), "fake-panic")
+	})
+	t.Run("recover should keep the error when there is no panic", func(t *testing.T) {
+		assert.NoError(t, func() (err error) {
+			defer Recover(context.Background(), logger.NewLogger("test"), &err)
+			return nil
+		}())
+	})
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrShuttingDown is returned by stream handlers when a new message arrives while the server is shutting down.
+var ErrShuttingDown = errors.New("component server is shutting down")
+
+type shutdownKey struct{}
+
+// WithShutdown returns a copy of the parent context carrying the given shutdown signal.
+func WithShutdown(ctx context.Context, shutdown <-chan struct{}) context.Context {
+	return context.WithValue(ctx, shutdownKey{}, shutdown)
+}
+
+// ShutdownFromContext returns the shutdown signal carried by the context.
+// when none signal is present a nil channel is returned, which never fires.
+func ShutdownFromContext(ctx context.Context) <-chan struct{} {
+	shutdown, _ := ctx.Value(shutdownKey{}).(<-chan struct{})
+	return shutdown
+}
+
+// IsShuttingDown returns true if the given shutdown signal has been fired.
+func IsShuttingDown(shutdown <-chan struct{}) bool {
+	select {
+	case <-shutdown:
+		return true
+	default:
+		return false
+	}
+}
+
+// DrainAcks runs the given ack loop until it returns or, in case of the shutdown signal is fired,
+// until all pending acks are settled. Handlers should stop handing new messages once the shutdown starts,
+// while the component keeps running so the in-flight messages can still be acked: its context should only
+// be canceled once DrainAcks returns, which happens when the server stops the stream after the drain timeout at the latest.
+func DrainAcks[TAckResult any](shutdown <-chan struct{}, ackLoop func() error, ackManager *AcknowledgementManager[TAckResult]) error {
+	ackLoopErr := make(chan error, 1)
+	go func() {
+		ackLoopErr <- ackLoop()
+	}()
+
+	select {
+	case err := <-ackLoopErr:
+		return err
+	case <-shutdown:
+	}
+
+	select {
+	case err := <-ackLoopErr:
+		return err
+	case <-ackManager.Drained():
+		return nil
+	}
+}
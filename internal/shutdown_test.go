@@ -0,0 +1,68 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdown(t *testing.T) {
+	t.Run("shutdown from context should return nil when not present", func(t *testing.T) {
+		assert.Nil(t, ShutdownFromContext(context.Background()))
+	})
+	t.Run("shutdown from context should return the given shutdown signal", func(t *testing.T) {
+		shutdown := make(chan struct{})
+		ctx := WithShutdown(context.Background(), shutdown)
+		assert.False(t, IsShuttingDown(ShutdownFromContext(ctx)))
+		close(shutdown)
+		assert.True(t, IsShuttingDown(ShutdownFromContext(ctx)))
+	})
+	t.Run("drain acks should return the ack loop result when not shutting down", func(t *testing.T) {
+		fakeErr := errors.New("fake-err")
+		err := DrainAcks(nil, func() error {
+			return fakeErr
+		}, NewAckManager[error]())
+		assert.Equal(t, fakeErr, err)
+	})
+	t.Run("drain acks should wait pending acks to be settled when shutting down", func(t *testing.T) {
+		shutdown := make(chan struct{})
+		manager := NewAckManager[error]()
+		_, _, cleanup := manager.Get()
+		blockAckLoop := make(chan struct{})
+		defer close(blockAckLoop)
+
+		drainErr := make(chan error, 1)
+		go func() {
+			drainErr <- DrainAcks(shutdown, func() error {
+				<-blockAckLoop
+				return nil
+			}, manager)
+		}()
+		close(shutdown)
+		select {
+		case <-drainErr:
+			t.Fatal("drain acks returned with a pending ack")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		cleanup()
+		assert.Nil(t, <-drainErr)
+		assert.Empty(t, manager.Pending())
+	})
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dapr
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dapr/kit/logger"
+)
+
+// ErrInvalidClientCA is returned when the client CA file doesn't contain any valid certificate.
+var ErrInvalidClientCA = errors.New("client CA file does not contain any valid PEM certificate")
+
+// Listener creates the network listener a component is served on.
+type Listener interface {
+	// Address returns the address the component registered with the given name listens on.
+	Address(name, socketFolder string) string
+	// Listen announces on the given address.
+	Listen(address string) (net.Listener, error)
+}
+
+// loggingListener is implemented by the listeners that log, the server gives them its logger before listening.
+type loggingListener interface {
+	withLogger(log logger.Logger) Listener
+}
+
+// unixSocketListener listens on a unix socket named after the component within the socket folder.
+type unixSocketListener struct{}
+
+// UnixSocketListener returns the default listener, a unix socket named after the component within the sockets folder.
+func UnixSocketListener() Listener {
+	return unixSocketListener{}
+}
+
+func (unixSocketListener) Address(name, socketFolder string) string {
+	return filepath.Join(socketFolder, name+".sock")
+}
+
+func (unixSocketListener) Listen(socket string) (net.Listener, error) {
+	// remove socket if it is already created.
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return net.Listen("unix", socket)
+}
+
+// tcpListener listens on a TCP address.
+type tcpListener struct {
+	address string
+}
+
+// TCPListener returns a listener that listens on the given TCP address, e.g. ":50051".
+func TCPListener(address string) Listener {
+	return tcpListener{address: address}
+}
+
+func (l tcpListener) Address(string, string) string {
+	return l.address
+}
+
+func (tcpListener) Listen(address string) (net.Listener, error) {
+	return net.Listen("tcp", address)
+}
+
+// TLSFiles are the PEM encoded files used by TLS listeners.
+// the files are reloaded when changed so certificates can be rotated without restarting the server.
+type TLSFiles struct {
+	// CertFile is the server certificate file.
+	CertFile string
+	// KeyFile is the server private key file.
+	KeyFile string
+	// ClientCAFile is the optional CA bundle used to verify clients certificates.
+	// when set, clients are required to present a valid certificate (mutual TLS).
+	ClientCAFile string
+}
+
+// tlsListener listens on a TCP address using TLS.
+type tlsListener struct {
+	tcpListener
+	files TLSFiles
+	log   logger.Logger
+}
+
+// TLSListener returns a listener that listens on the given TCP address using TLS with the given certificates.
+func TLSListener(address string, files TLSFiles) Listener {
+	return tlsListener{
+		tcpListener: tcpListener{address: address},
+		files:       files,
+		log:         svcLogger,
+	}
+}
+
+func (l tlsListener) withLogger(log logger.Logger) Listener {
+	l.log = log
+	return l
+}
+
+func (l tlsListener) Listen(address string) (net.Listener, error) {
+	reloader := &tlsReloader{files: l.files, log: l.log}
+	// load the certificates eagerly so invalid files are reported before serving.
+	if _, err := reloader.config(); err != nil {
+		return nil, err
+	}
+
+	lis, err := l.tcpListener.Listen(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return tls.NewListener(lis, &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return reloader.config()
+		},
+	}), nil
+}
+
+// tlsReloader caches the tls config and reloads it when any of the files is modified.
+type tlsReloader struct {
+	files    TLSFiles
+	log      logger.Logger
+	mu       sync.Mutex
+	modTimes []time.Time
+	cached   *tls.Config
+}
+
+// modTimes returns the modification time of each configured file.
+func (r *tlsReloader) currentModTimes() ([]time.Time, error) {
+	files := []string{r.files.CertFile, r.files.KeyFile}
+	if r.files.ClientCAFile != "" {
+		files = append(files, r.files.ClientCAFile)
+	}
+	modTimes := make([]time.Time, len(files))
+	for idx, file := range files {
+		stat, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[idx] = stat.ModTime()
+	}
+	return modTimes, nil
+}
+
+func sameModTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if !a[idx].Equal(b[idx]) {
+			return false
+		}
+	}
+	return true
+}
+
+// config returns the current tls config, reloading it when the files have changed.
+// in case of the reload fails while a previous config is available the previous config is kept.
+func (r *tlsReloader) config() (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTimes, err := r.currentModTimes()
+	if err == nil && r.cached != nil && sameModTimes(modTimes, r.modTimes) {
+		return r.cached, nil
+	}
+
+	var config *tls.Config
+	if err == nil {
+		config, err = r.load()
+	}
+
+	if err != nil {
+		if r.cached != nil {
+			r.log.Warnf("could not reload TLS certificates, keeping the previous ones: %v", err)
+			return r.cached, nil
+		}
+		return nil, err
+	}
+
+	r.cached, r.modTimes = config, modTimes
+	return config, nil
+}
+
+// load reads the certificates from the files.
+func (r *tlsReloader) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(r.files.CertFile, r.files.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load TLS key pair: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if r.files.ClientCAFile == "" {
+		return config, nil
+	}
+
+	caPEM, err := os.ReadFile(r.files.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read client CA file: %w", err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, ErrInvalidClientCA
+	}
+
+	config.ClientCAs = clientCAs
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
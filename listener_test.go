@@ -0,0 +1,166 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dapr
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dapr/kit/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSelfSignedCert writes a self signed certificate and its key to the given files.
+func writeSelfSignedCert(t *testing.T, certFile, keyFile, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+}
+
+func TestListener(t *testing.T) {
+	t.Run("unix socket listener should use the component name within the socket folder", func(t *testing.T) {
+		assert.Equal(t, filepath.Join("/tmp", "my-component.sock"), UnixSocketListener().Address("my-component", "/tmp"))
+	})
+
+	t.Run("tcp listener should ignore the socket folder", func(t *testing.T) {
+		lis := TCPListener("127.0.0.1:0")
+		assert.Equal(t, "127.0.0.1:0", lis.Address("my-component", "/tmp"))
+		l, err := lis.Listen("127.0.0.1:0")
+		require.NoError(t, err)
+		assert.Equal(t, "tcp", l.Addr().Network())
+		l.Close()
+	})
+
+	t.Run("tls listener should fail when certificates are invalid", func(t *testing.T) {
+		dir := t.TempDir()
+		_, err := TLSListener("127.0.0.1:0", TLSFiles{
+			CertFile: filepath.Join(dir, "cert.pem"),
+			KeyFile:  filepath.Join(dir, "key.pem"),
+		}).Listen("127.0.0.1:0")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("tls listener should log through the given logger", func(t *testing.T) {
+		log := logger.NewLogger("fake-server")
+		listener, ok := TLSListener(":0", TLSFiles{}).(loggingListener)
+		require.True(t, ok)
+		assert.Equal(t, log, listener.withLogger(log).(tlsListener).log)
+	})
+
+	t.Run("tls listener should accept clients with valid certificates", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		writeSelfSignedCert(t, certFile, keyFile, "server")
+
+		l, err := TLSListener("127.0.0.1:0", TLSFiles{
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			ClientCAFile: certFile,
+		}).Listen("127.0.0.1:0")
+		require.NoError(t, err)
+		defer l.Close()
+
+		go func() {
+			conn, err := l.Accept()
+			if err == nil {
+				_ = conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}
+		}()
+
+		clientCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		require.NoError(t, err)
+
+		// the test certificate has no SANs, so the server verification is skipped.
+		conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			Certificates:       []tls.Certificate{clientCert},
+			InsecureSkipVerify: true, //nolint:gosec
+		})
+		require.NoError(t, err)
+		require.NoError(t, conn.Handshake())
+		conn.Close()
+	})
+
+	t.Run("tls reloader should reload certificates when files change", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		writeSelfSignedCert(t, certFile, keyFile, "first")
+
+		reloader := &tlsReloader{files: TLSFiles{CertFile: certFile, KeyFile: keyFile}, log: svcLogger}
+		first, err := reloader.config()
+		require.NoError(t, err)
+		cached, err := reloader.config()
+		require.NoError(t, err)
+		assert.Same(t, first, cached)
+
+		writeSelfSignedCert(t, certFile, keyFile, "second")
+		future := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(certFile, future, future))
+
+		second, err := reloader.config()
+		require.NoError(t, err)
+		assert.NotSame(t, first, second)
+		leaf, err := x509.ParseCertificate(second.Certificates[0].Certificate[0])
+		require.NoError(t, err)
+		assert.Equal(t, "second", leaf.Subject.CommonName)
+	})
+
+	t.Run("tls reloader should keep previous certificates when reload fails", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		writeSelfSignedCert(t, certFile, keyFile, "first")
+
+		reloader := &tlsReloader{files: TLSFiles{CertFile: certFile, KeyFile: keyFile}, log: svcLogger}
+		first, err := reloader.config()
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(keyFile, []byte("invalid"), 0o600))
+		future := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(keyFile, future, future))
+
+		current, err := reloader.config()
+		require.NoError(t, err)
+		assert.Same(t, first, current)
+	})
+}
@@ -15,38 +15,482 @@ package dapr
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"sync"
+	"time"
 
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+	"github.com/dapr/kit/logger"
+
+	sdkerrors "github.com/dapr-sandbox/components-go-sdk/errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
-const metadataInstanceID = "x-component-instance"
-
-// mux returns a function that creates and store new instances based on `x-component-instance` metadata header.
-// when no component instance is provided so a default instance is used instead.
-func mux[TComponent any](new func() TComponent) func(context.Context) TComponent {
-	instances := sync.Map{}
-	firstLoad := sync.Mutex{}
-	return func(ctx context.Context) TComponent {
-		instanceID := "#default__instance#"
-		metadata, ok := metadata.FromIncomingContext(ctx)
-		if ok {
-			instanceIDs := metadata.Get(metadataInstanceID)
-			if len(instanceIDs) != 0 {
-				instanceID = instanceIDs[0]
+const (
+	metadataInstanceID = "x-component-instance"
+	defaultInstanceID  = "#default__instance#"
+)
+
+// instancePolicy configures when component instances are evicted.
+type instancePolicy struct {
+	// idleTTL is how long an instance can stay unused before being evicted, zero means never.
+	idleTTL time.Duration
+	// maxInstances is the maximum number of cached instances, zero means unlimited.
+	maxInstances int
+}
+
+// instanceIDFromIncomingContext returns the instance ID from the `x-component-instance` metadata header.
+// when no component instance is provided so the default instance ID is used instead.
+func instanceIDFromIncomingContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if ok {
+		instanceIDs := md.Get(metadataInstanceID)
+		if len(instanceIDs) != 0 {
+			return instanceIDs[0]
+		}
+	}
+	return defaultInstanceID
+}
+
+// instance is a cached component instance.
+type instance[TComponent any] struct {
+	component TComponent
+	lastUsed  time.Time
+}
+
+// creation is an instance being created, done is closed once it is created or failed.
+type creation[TComponent any] struct {
+	done      chan struct{}
+	component TComponent
+	err       error
+}
+
+// evicted is an instance removed from the cache, it is closed once the lock is released.
+type evicted[TComponent any] struct {
+	instanceID string
+	component  TComponent
+}
+
+// initCallKey marks the context of the Init calls.
+type initCallKey struct{}
+
+// withInitCall returns a copy of the parent context marked as an Init call, which initializes the instance by itself.
+func withInitCall(ctx context.Context) context.Context {
+	return context.WithValue(ctx, initCallKey{}, true)
+}
+
+// isInitCall returns whether the context belongs to an Init call.
+func isInitCall(ctx context.Context) bool {
+	initCall, _ := ctx.Value(initCallKey{}).(bool)
+	return initCall
+}
+
+// instances creates and store new instances based on `x-component-instance` metadata header.
+// It also manages their lifecycle: instances are closed on eviction and on shutdown,
+// and an instance whose Init has failed is replaced on the next call.
+type instances[TComponent any] struct {
+	new    func(context.Context, InstanceInfo) (TComponent, error)
+	init   func(context.Context, TComponent, map[string]string) error
+	policy instancePolicy
+	// info is the identity shared by all instances, the instance ID is set on each creation.
+	info InstanceInfo
+	now  func() time.Time
+	log  logger.Logger
+
+	mu        sync.Mutex
+	instances map[string]*instance[TComponent]
+	// creating holds the instances being created, so concurrent calls wait for the same creation.
+	creating map[string]*creation[TComponent]
+	inUse    map[string]int
+	// closing holds the evicted instances that were still in use, they are closed on the last release of their ID.
+	closing map[string][]TComponent
+	// properties are the init properties of each instance, used to initialize it again after being evicted.
+	properties map[string]map[string]string
+}
+
+// newInstances creates a new instances multiplexer using the given factory.
+// the init function is used to initialize instances that were re-created after an eviction.
+func newInstances[TComponent any](new func(context.Context, InstanceInfo) (TComponent, error), init func(context.Context, TComponent, map[string]string) error, policy instancePolicy, info InstanceInfo, log logger.Logger) *instances[TComponent] {
+	return &instances[TComponent]{
+		new:        new,
+		init:       init,
+		policy:     policy,
+		info:       info,
+		now:        time.Now,
+		log:        log,
+		instances:  make(map[string]*instance[TComponent]),
+		creating:   make(map[string]*creation[TComponent]),
+		inUse:      make(map[string]int),
+		closing:    make(map[string][]TComponent),
+		properties: make(map[string]map[string]string),
+	}
+}
+
+// get returns the instance associated with the context, creating it when it doesn't exist yet.
+// instances are created without holding the lock, concurrent calls for the same instance wait for its creation.
+// the returned error is a gRPC status error, it is returned when the instance could not be created.
+func (m *instances[TComponent]) get(ctx context.Context) (TComponent, error) {
+	instanceID := instanceIDFromIncomingContext(ctx)
+
+	m.mu.Lock()
+	if inst, ok := m.instances[instanceID]; ok {
+		inst.lastUsed = m.now()
+		m.mu.Unlock()
+		return inst.component, nil
+	}
+	if c, ok := m.creating[instanceID]; ok {
+		m.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.component, c.err
+		case <-ctx.Done():
+			var zero TComponent
+			return zero, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	c := &creation[TComponent]{done: make(chan struct{})}
+	m.creating[instanceID] = c
+	properties, initialized := m.properties[instanceID]
+	m.mu.Unlock()
+
+	// the instance was evicted after being initialized, so it should be initialized again,
+	// unless the call is an Init itself.
+	c.component, c.err = m.create(ctx, instanceID, properties, initialized && !isInitCall(ctx))
+
+	m.mu.Lock()
+	delete(m.creating, instanceID)
+	var toClose []evicted[TComponent]
+	if c.err == nil {
+		m.instances[instanceID] = &instance[TComponent]{
+			component: c.component,
+			lastUsed:  m.now(),
+		}
+		if m.policy.maxInstances > 0 && len(m.instances) > m.policy.maxInstances {
+			toClose = m.evictLeastRecentlyUsedLocked(instanceID)
+		}
+	}
+	m.mu.Unlock()
+	close(c.done)
+
+	m.closeEvicted(toClose)
+	return c.component, c.err
+}
+
+// create creates a new instance, initializing it with the given properties when init is true.
+func (m *instances[TComponent]) create(ctx context.Context, instanceID string, properties map[string]string, init bool) (TComponent, error) {
+	info := m.info
+	info.InstanceID = visibleInstanceID(instanceID)
+
+	var zero TComponent
+	component, err := m.new(ctx, info)
+	if err != nil {
+		return zero, instanceStatusError(err, "could not create instance %s of %s", instanceID, info.Name)
+	}
+	if init && m.init != nil {
+		if err := m.init(ctx, component, properties); err != nil {
+			if closeErr := closeInstance(instanceID, component); closeErr != nil {
+				m.log.Warn(closeErr)
 			}
+			return zero, instanceStatusError(err, "could not initialize instance %s of %s again after eviction", instanceID, info.Name)
 		}
-		instance, ok := instances.Load(instanceID)
-		if !ok {
-			firstLoad.Lock()
-			defer firstLoad.Unlock()
-			instance, ok = instances.Load(instanceID) // double check lock
-			if ok {
-				return instance.(TComponent)
+	}
+	return component, nil
+}
+
+// instanceStatusError returns the error as is when it is already a gRPC status error, or an error of the errors package,
+// otherwise it wraps the error into an internal error status using the given message.
+func instanceStatusError(err error, format string, args ...any) error {
+	err = sdkerrors.ToGRPC(err)
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Errorf(codes.Internal, "%s: %v", fmt.Sprintf(format, args...), err)
+}
+
+// acquire marks the instance as in use, in use instances are never evicted.
+func (m *instances[TComponent]) acquire(instanceID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inUse[instanceID]++
+}
+
+// release marks the instance as no longer used by a call, the instances evicted while in use are closed on the last release.
+func (m *instances[TComponent]) release(instanceID string) {
+	m.mu.Lock()
+	var toClose []evicted[TComponent]
+	m.inUse[instanceID]--
+	if m.inUse[instanceID] <= 0 {
+		delete(m.inUse, instanceID)
+		for _, component := range m.closing[instanceID] {
+			toClose = append(toClose, evicted[TComponent]{instanceID: instanceID, component: component})
+		}
+		delete(m.closing, instanceID)
+	}
+	if inst, ok := m.instances[instanceID]; ok {
+		inst.lastUsed = m.now()
+	}
+	m.mu.Unlock()
+	m.closeEvicted(toClose)
+}
+
+// evict removes the given instance and closes it once it is no longer in use, it is created and initialized again on the next call.
+func (m *instances[TComponent]) evict(instanceID string) {
+	m.mu.Lock()
+	toClose := m.evictLocked(instanceID)
+	m.mu.Unlock()
+	m.closeEvicted(toClose)
+}
+
+// initialized records the result of the instance initialization.
+// failed instances are discarded so the next call creates a new one.
+func (m *instances[TComponent]) initialized(instanceID string, properties map[string]string, err error) {
+	m.mu.Lock()
+	if err == nil {
+		m.properties[instanceID] = properties
+		m.mu.Unlock()
+		return
+	}
+
+	delete(m.properties, instanceID)
+	toClose := m.evictLocked(instanceID)
+	m.mu.Unlock()
+	m.closeEvicted(toClose)
+}
+
+// evictLocked removes the given instance and returns it, so it is closed once the lock is released.
+// instances that are still in use are not returned, they are closed on their last release instead.
+func (m *instances[TComponent]) evictLocked(instanceID string) []evicted[TComponent] {
+	inst, ok := m.instances[instanceID]
+	if !ok {
+		return nil
+	}
+	delete(m.instances, instanceID)
+	if m.inUse[instanceID] > 0 {
+		m.closing[instanceID] = append(m.closing[instanceID], inst.component)
+		return nil
+	}
+	return []evicted[TComponent]{{instanceID: instanceID, component: inst.component}}
+}
+
+// closeEvicted closes the given evicted instances, logging the errors.
+func (m *instances[TComponent]) closeEvicted(instances []evicted[TComponent]) {
+	for _, inst := range instances {
+		if err := closeInstance(inst.instanceID, inst.component); err != nil {
+			m.log.Warn(err)
+		}
+	}
+}
+
+// evictLeastRecentlyUsedLocked removes the least recently used instance that is not in use and returns it.
+func (m *instances[TComponent]) evictLeastRecentlyUsedLocked(keep string) []evicted[TComponent] {
+	var (
+		lruID   string
+		lruTime time.Time
+	)
+	for instanceID, inst := range m.instances {
+		if instanceID == keep || m.inUse[instanceID] > 0 {
+			continue
+		}
+		if lruID == "" || inst.lastUsed.Before(lruTime) {
+			lruID, lruTime = instanceID, inst.lastUsed
+		}
+	}
+	if lruID == "" {
+		m.log.Warnf("max instances reached (%d) but all instances are in use", m.policy.maxInstances)
+		return nil
+	}
+	return m.evictLocked(lruID)
+}
+
+// evictIdle evicts all instances that were not used within the idle TTL.
+func (m *instances[TComponent]) evictIdle() {
+	if m.policy.idleTTL <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	var toClose []evicted[TComponent]
+	deadline := m.now().Add(-m.policy.idleTTL)
+	for instanceID, inst := range m.instances {
+		if m.inUse[instanceID] == 0 && inst.lastUsed.Before(deadline) {
+			toClose = append(toClose, m.evictLocked(instanceID)...)
+		}
+	}
+	m.mu.Unlock()
+	m.closeEvicted(toClose)
+}
+
+// closeAll closes all instances, including the evicted ones waiting for their last release.
+func (m *instances[TComponent]) closeAll() error {
+	m.mu.Lock()
+	instances, closing := m.instances, m.closing
+	m.instances = make(map[string]*instance[TComponent])
+	m.closing = make(map[string][]TComponent)
+	m.mu.Unlock()
+
+	var errs []error
+	for instanceID, inst := range instances {
+		if err := closeInstance(instanceID, inst.component); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for instanceID, components := range closing {
+		for _, component := range components {
+			if err := closeInstance(instanceID, component); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// closeInstance closes the component when it implements io.Closer.
+func closeInstance(instanceID string, component any) error {
+	closer, ok := component.(io.Closer)
+	if !ok {
+		return nil
+	}
+	if err := closer.Close(); err != nil {
+		return fmt.Errorf("error when closing instance %s: %w", instanceID, err)
+	}
+	return nil
+}
+
+// instancesManager is the lifecycle interface of the instances of a component service.
+type instancesManager interface {
+	acquire(instanceID string)
+	release(instanceID string)
+	initialized(instanceID string, properties map[string]string, err error)
+	evict(instanceID string)
+	evictIdle()
+	closeAll() error
+}
+
+// instancesRegistry holds the instances managers of all services served on a socket.
+type instancesRegistry struct {
+	// name is the name the component was registered with.
+	name     string
+	policy   instancePolicy
+	log      logger.Logger
+	managers map[string]instancesManager
+}
+
+func newInstancesRegistry(name string, policy instancePolicy, log logger.Logger) *instancesRegistry {
+	return &instancesRegistry{
+		name:     name,
+		policy:   policy,
+		log:      log,
+		managers: make(map[string]instancesManager),
+	}
+}
+
+// instanceInfo returns the identity shared by all instances of the given component type.
+func (r *instancesRegistry) instanceInfo(componentType ComponentType) InstanceInfo {
+	return InstanceInfo{Name: r.name, Type: componentType}
+}
+
+// add registers the manager for the given service and its related services.
+func (r *instancesRegistry) add(service string, manager instancesManager) {
+	r.managers[service] = manager
+	for _, related := range relatedServices[service] {
+		r.managers[related] = manager
+	}
+}
+
+// evictIdleEvery evicts idle instances periodically until the context is done.
+func (r *instancesRegistry) evictIdleEvery(ctx context.Context) {
+	if r.policy.idleTTL <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.policy.idleTTL / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, manager := range r.uniqueManagers() {
+				manager.evictIdle()
 			}
-			instance = new()
-			instances.Store(instanceID, instance)
 		}
-		return instance.(TComponent)
+	}
+}
+
+// uniqueManagers returns the registered managers without duplicates.
+func (r *instancesRegistry) uniqueManagers() []instancesManager {
+	seen := make(map[instancesManager]struct{}, len(r.managers))
+	managers := make([]instancesManager, 0, len(r.managers))
+	for _, manager := range r.managers {
+		if _, ok := seen[manager]; ok {
+			continue
+		}
+		seen[manager] = struct{}{}
+		managers = append(managers, manager)
+	}
+	return managers
+}
+
+// closeAll closes all instances of all services.
+func (r *instancesRegistry) closeAll() error {
+	var errs []error
+	for _, manager := range r.uniqueManagers() {
+		if err := manager.closeAll(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// initRequest is implemented by all components init requests.
+type initRequest interface {
+	GetMetadata() *proto.MetadataRequest
+}
+
+// instancesUnaryInterceptor marks instances as in use during unary calls and records the Init results.
+func instancesUnaryInterceptor(registry *instancesRegistry) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		service, method := splitMethod(info.FullMethod)
+		manager, ok := registry.managers[service]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		instanceID := instanceIDFromIncomingContext(ctx)
+		manager.acquire(instanceID)
+		defer manager.release(instanceID)
+
+		initReq, isInit := req.(initRequest)
+		isInit = isInit && method == "Init"
+		if isInit {
+			ctx = withInitCall(ctx)
+		}
+		resp, err := handler(ctx, req)
+		if isInit {
+			manager.initialized(instanceID, initReq.GetMetadata().GetProperties(), err)
+		}
+		return resp, err
+	}
+}
+
+// instancesStreamInterceptor marks instances as in use while their streams are open.
+func instancesStreamInterceptor(registry *instancesRegistry) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		service, _ := splitMethod(info.FullMethod)
+		manager, ok := registry.managers[service]
+		if !ok {
+			return handler(srv, ss)
+		}
+
+		instanceID := instanceIDFromIncomingContext(ss.Context())
+		manager.acquire(instanceID)
+		defer manager.release(instanceID)
+
+		return handler(srv, ss)
 	}
 }
@@ -15,12 +15,41 @@ package dapr
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
+type fakeCloser struct {
+	id          int
+	closeCalled int
+}
+
+func (f *fakeCloser) Close() error {
+	f.closeCalled++
+	return nil
+}
+
+// instanceCtx returns an incoming context for the given instance ID.
+func instanceCtx(instanceID string) context.Context {
+	return metadata.NewIncomingContext(context.TODO(), metadata.Pairs(metadataInstanceID, instanceID))
+}
+
+// mustGet returns the instance with the given ID failing the test in case of error.
+func mustGet[TComponent any](t *testing.T, instances *instances[TComponent], instanceID string) TComponent {
+	t.Helper()
+	component, err := instances.get(instanceCtx(instanceID))
+	require.NoError(t, err)
+	return component
+}
+
 func TestMultiplexer(t *testing.T) {
 	t.Run("mux should use default instance when metadata is not present", func(t *testing.T) {
 		called := 0
@@ -28,7 +57,7 @@ func TestMultiplexer(t *testing.T) {
 			called++
 			return 0
 		}
-		factory := mux(fakeFactory)
+		factory := newInstances(infallible(fakeFactory), nil, instancePolicy{}, InstanceInfo{}, svcLogger).get
 		factory(context.TODO())
 		assert.Equal(t, 1, called)
 		factory(context.TODO())
@@ -40,7 +69,7 @@ func TestMultiplexer(t *testing.T) {
 			called++
 			return 0
 		}
-		factory := mux(fakeFactory)
+		factory := newInstances(infallible(fakeFactory), nil, instancePolicy{}, InstanceInfo{}, svcLogger).get
 
 		factory(metadata.NewIncomingContext(context.TODO(), metadata.Pairs("a", "b")))
 		assert.Equal(t, 1, called)
@@ -53,11 +82,197 @@ func TestMultiplexer(t *testing.T) {
 			called++
 			return 0
 		}
-		factory := mux(fakeFactory)
+		factory := newInstances(infallible(fakeFactory), nil, instancePolicy{}, InstanceInfo{}, svcLogger).get
 
 		factory(metadata.NewIncomingContext(context.TODO(), metadata.Pairs(metadataInstanceID, "x")))
 		assert.Equal(t, 1, called)
 		factory(metadata.NewIncomingContext(context.TODO(), metadata.Pairs(metadataInstanceID, "x")))
 		assert.Equal(t, 1, called)
 	})
+
+	t.Run("close all should close all instances that implement io.Closer", func(t *testing.T) {
+		instances := newInstances(infallible(func() *fakeCloser { return &fakeCloser{} }), nil, instancePolicy{}, InstanceInfo{}, svcLogger)
+		a, b := mustGet(t, instances, "a"), mustGet(t, instances, "b")
+		assert.Nil(t, instances.closeAll())
+		assert.Equal(t, 1, a.closeCalled)
+		assert.Equal(t, 1, b.closeCalled)
+	})
+	t.Run("instances whose init failed should be replaced on the next call", func(t *testing.T) {
+		created := 0
+		instances := newInstances(infallible(func() *fakeCloser {
+			created++
+			return &fakeCloser{id: created}
+		}), nil, instancePolicy{}, InstanceInfo{}, svcLogger)
+		failed := mustGet(t, instances, "x")
+		instances.initialized("x", nil, errors.New("fake-init-err"))
+		assert.Equal(t, 1, failed.closeCalled)
+		replaced := mustGet(t, instances, "x")
+		assert.NotEqual(t, failed.id, replaced.id)
+	})
+	t.Run("idle instances should be evicted and initialized again with the same properties", func(t *testing.T) {
+		now := time.Now()
+		var initProperties map[string]string
+		instances := newInstances(infallible(func() *fakeCloser { return &fakeCloser{} }), func(_ context.Context, _ *fakeCloser, properties map[string]string) error {
+			initProperties = properties
+			return nil
+		}, instancePolicy{idleTTL: time.Minute}, InstanceInfo{}, svcLogger)
+		instances.now = func() time.Time { return now }
+
+		evicted := mustGet(t, instances, "x")
+		instances.initialized("x", map[string]string{"a": "b"}, nil)
+
+		now = now.Add(2 * time.Minute)
+		instances.evictIdle()
+		assert.Equal(t, 1, evicted.closeCalled)
+		assert.Nil(t, initProperties)
+
+		assert.NotSame(t, evicted, mustGet(t, instances, "x"))
+		assert.Equal(t, map[string]string{"a": "b"}, initProperties)
+	})
+	t.Run("in use instances should not be evicted", func(t *testing.T) {
+		now := time.Now()
+		instances := newInstances(infallible(func() *fakeCloser { return &fakeCloser{} }), nil, instancePolicy{idleTTL: time.Minute}, InstanceInfo{}, svcLogger)
+		instances.now = func() time.Time { return now }
+
+		instances.acquire("x")
+		inUse := mustGet(t, instances, "x")
+		now = now.Add(2 * time.Minute)
+		instances.evictIdle()
+		assert.Equal(t, 0, inUse.closeCalled)
+
+		instances.release("x")
+		now = now.Add(2 * time.Minute)
+		instances.evictIdle()
+		assert.Equal(t, 1, inUse.closeCalled)
+	})
+	t.Run("instances evicted while in use should be closed on their last release", func(t *testing.T) {
+		instances := newInstances(infallible(func() *fakeCloser { return &fakeCloser{} }), nil, instancePolicy{}, InstanceInfo{}, svcLogger)
+
+		instances.acquire("x")
+		instances.acquire("x")
+		evicted := mustGet(t, instances, "x")
+		instances.initialized("x", nil, errors.New("fake-init-err"))
+		assert.Equal(t, 0, evicted.closeCalled)
+		assert.NotSame(t, evicted, mustGet(t, instances, "x"))
+
+		instances.release("x")
+		assert.Equal(t, 0, evicted.closeCalled)
+		instances.release("x")
+		assert.Equal(t, 1, evicted.closeCalled)
+	})
+	t.Run("least recently used instance should be evicted when max instances is reached", func(t *testing.T) {
+		now := time.Now()
+		instances := newInstances(infallible(func() *fakeCloser { return &fakeCloser{} }), nil, instancePolicy{maxInstances: 2}, InstanceInfo{}, svcLogger)
+		instances.now = func() time.Time { return now }
+
+		a := mustGet(t, instances, "a")
+		now = now.Add(time.Second)
+		b := mustGet(t, instances, "b")
+		now = now.Add(time.Second)
+		mustGet(t, instances, "a")
+		now = now.Add(time.Second)
+		mustGet(t, instances, "c")
+
+		assert.Equal(t, 0, a.closeCalled)
+		assert.Equal(t, 1, b.closeCalled)
+		assert.Len(t, instances.instances, 2)
+	})
+	t.Run("factory should receive the instance identity", func(t *testing.T) {
+		var received []InstanceInfo
+		instances := newInstances(func(_ context.Context, info InstanceInfo) (int, error) {
+			received = append(received, info)
+			return 0, nil
+		}, nil, instancePolicy{}, InstanceInfo{Name: "my-component", Type: ComponentTypeStateStore}, svcLogger)
+
+		mustGet(t, instances, "x")
+		_, err := instances.get(context.TODO())
+		require.NoError(t, err)
+
+		assert.Equal(t, []InstanceInfo{
+			{InstanceID: "x", Name: "my-component", Type: ComponentTypeStateStore},
+			{Name: "my-component", Type: ComponentTypeStateStore},
+		}, received)
+	})
+	t.Run("factory errors should be returned as internal status errors", func(t *testing.T) {
+		instances := newInstances(func(context.Context, InstanceInfo) (int, error) {
+			return 0, errors.New("fake-factory-err")
+		}, nil, instancePolicy{}, InstanceInfo{}, svcLogger)
+
+		_, err := instances.get(instanceCtx("x"))
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Contains(t, err.Error(), "fake-factory-err")
+		assert.Empty(t, instances.instances)
+	})
+	t.Run("factory status errors should be returned as is", func(t *testing.T) {
+		factoryErr := status.Error(codes.Unavailable, "fake-factory-err")
+		instances := newInstances(func(context.Context, InstanceInfo) (int, error) {
+			return 0, factoryErr
+		}, nil, instancePolicy{}, InstanceInfo{}, svcLogger)
+
+		_, err := instances.get(instanceCtx("x"))
+		assert.Equal(t, factoryErr, err)
+	})
+	t.Run("instances that failed to initialize again after eviction should not be cached", func(t *testing.T) {
+		now := time.Now()
+		instances := newInstances(infallible(func() *fakeCloser { return &fakeCloser{} }), func(context.Context, *fakeCloser, map[string]string) error {
+			return errors.New("fake-init-err")
+		}, instancePolicy{idleTTL: time.Minute}, InstanceInfo{}, svcLogger)
+		instances.now = func() time.Time { return now }
+
+		mustGet(t, instances, "x")
+		instances.initialized("x", map[string]string{}, nil)
+		now = now.Add(2 * time.Minute)
+		instances.evictIdle()
+
+		_, err := instances.get(instanceCtx("x"))
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Empty(t, instances.instances)
+	})
+	t.Run("init calls should not initialize evicted instances again", func(t *testing.T) {
+		initCalled := 0
+		instances := newInstances(infallible(func() *fakeCloser { return &fakeCloser{} }), func(context.Context, *fakeCloser, map[string]string) error {
+			initCalled++
+			return nil
+		}, instancePolicy{}, InstanceInfo{}, svcLogger)
+
+		mustGet(t, instances, "x")
+		instances.initialized("x", map[string]string{"a": "b"}, nil)
+		instances.evict("x")
+
+		_, err := instances.get(withInitCall(instanceCtx("x")))
+		require.NoError(t, err)
+		assert.Equal(t, 0, initCalled)
+
+		instances.evict("x")
+		mustGet(t, instances, "x")
+		assert.Equal(t, 1, initCalled)
+	})
+	t.Run("instances should be created once without blocking the other instances", func(t *testing.T) {
+		var created atomic.Int64
+		started, unblock := make(chan struct{}), make(chan struct{})
+		instances := newInstances(func(_ context.Context, info InstanceInfo) (*fakeCloser, error) {
+			created.Add(1)
+			if info.InstanceID == "slow" {
+				close(started)
+				<-unblock
+			}
+			return &fakeCloser{}, nil
+		}, nil, instancePolicy{}, InstanceInfo{}, svcLogger)
+
+		slow := make(chan *fakeCloser, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				component, err := instances.get(instanceCtx("slow"))
+				assert.NoError(t, err)
+				slow <- component
+			}()
+		}
+		<-started
+		mustGet(t, instances, "fast")
+		close(unblock)
+
+		first, second := <-slow, <-slow
+		assert.Same(t, first, second)
+		assert.Equal(t, int64(2), created.Load())
+	})
 }
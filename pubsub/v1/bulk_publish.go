@@ -0,0 +1,104 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	contribPubSub "github.com/dapr/components-contrib/pubsub"
+
+	"github.com/dapr-sandbox/components-go-sdk/internal"
+)
+
+// defaultBulkPublishParallelism is the number of concurrent publishes of emulated bulk publishes, unless configured otherwise.
+const defaultBulkPublishParallelism = 10
+
+// errBulkPublishFailed is the error of the failed entries reported without one.
+var errBulkPublishFailed = errors.New("bulk publish failed")
+
+// failedEntries returns the failed entries of a native bulk publish that returned the given response and error.
+// when the bulk publish fails, the entries the response does not report are failed with its error as their status is unknown.
+func failedEntries(req *contribPubSub.BulkPublishRequest, resp contribPubSub.BulkPublishResponse, err error) contribPubSub.BulkPublishResponse {
+	reported := make(map[string]struct{}, len(resp.FailedEntries))
+	failed := make([]contribPubSub.BulkPublishResponseFailedEntry, 0, len(resp.FailedEntries))
+	for _, entry := range resp.FailedEntries {
+		reported[entry.EntryId] = struct{}{}
+		if entry.Error == nil {
+			entry.Error = errBulkPublishFailed
+			if err != nil {
+				entry.Error = err
+			}
+		}
+		failed = append(failed, entry)
+	}
+	if err != nil {
+		for _, entry := range req.Entries {
+			if _, ok := reported[entry.EntryId]; !ok {
+				failed = append(failed, contribPubSub.BulkPublishResponseFailedEntry{EntryId: entry.EntryId, Error: err})
+			}
+		}
+	}
+	return contribPubSub.BulkPublishResponse{FailedEntries: failed}
+}
+
+// emulateBulkPublish publishes each entry of the given request through Publish, with the given parallelism (unlimited when zero or less),
+// and reports the entries that failed in their request order.
+func emulateBulkPublish(ctx context.Context, ps PubSub, req *contribPubSub.BulkPublishRequest, parallelism int) contribPubSub.BulkPublishResponse {
+	if parallelism <= 0 {
+		parallelism = len(req.Entries)
+	}
+	errs := make([]error, len(req.Entries))
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for idx := range req.Entries {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(idx int) {
+			// publishes run on their own goroutines, out of the reach of the panic recovery of the gRPC server.
+			defer func() {
+				if r := recover(); r != nil {
+					errs[idx] = internal.PanicError(ctx, pubsubLogger, r)
+				}
+				<-slots
+				wg.Done()
+			}()
+			entry := req.Entries[idx]
+			metadata := make(map[string]string, len(req.Metadata)+len(entry.Metadata))
+			for k, v := range req.Metadata {
+				metadata[k] = v
+			}
+			for k, v := range entry.Metadata {
+				metadata[k] = v
+			}
+			errs[idx] = ps.Publish(ctx, &contribPubSub.PublishRequest{
+				Data:        entry.Event,
+				PubsubName:  req.PubsubName,
+				Topic:       req.Topic,
+				Metadata:    metadata,
+				ContentType: &entry.ContentType,
+			})
+		}(idx)
+	}
+	wg.Wait()
+
+	var resp contribPubSub.BulkPublishResponse
+	for idx, err := range errs {
+		if err != nil {
+			resp.FailedEntries = append(resp.FailedEntries, contribPubSub.BulkPublishResponseFailedEntry{EntryId: req.Entries[idx].EntryId, Error: err})
+		}
+	}
+	return resp
+}
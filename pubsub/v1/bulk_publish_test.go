@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	contribPubSub "github.com/dapr/components-contrib/pubsub"
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBulkPublisherImpl struct {
+	fakePubSubImpl
+	bulkPublishResp contribPubSub.BulkPublishResponse
+	bulkPublishErr  error
+}
+
+func (f *fakeBulkPublisherImpl) BulkPublish(context.Context, *contribPubSub.BulkPublishRequest) (contribPubSub.BulkPublishResponse, error) {
+	return f.bulkPublishResp, f.bulkPublishErr
+}
+
+// fakeSlowPublisherImpl fails the messages whose data is "fail" and tracks its concurrent publishes.
+type fakeSlowPublisherImpl struct {
+	fakePubSubImpl
+	running    atomic.Int64
+	maxRunning atomic.Int64
+}
+
+func (f *fakeSlowPublisherImpl) Publish(_ context.Context, req *contribPubSub.PublishRequest) error {
+	running := f.running.Add(1)
+	defer f.running.Add(-1)
+	for {
+		maxRunning := f.maxRunning.Load()
+		if running <= maxRunning || f.maxRunning.CompareAndSwap(maxRunning, running) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	if string(req.Data) == "fail" {
+		return errors.New("fake-publish-err")
+	}
+	return nil
+}
+
+func bulkPublishRequest(events ...string) *proto.BulkPublishRequest {
+	req := &proto.BulkPublishRequest{Topic: "fake-topic"}
+	for idx, event := range events {
+		req.Entries = append(req.Entries, &proto.BulkMessageEntry{EntryId: string(rune('a' + idx)), Event: []byte(event)})
+	}
+	return req
+}
+
+func TestBulkPublish(t *testing.T) {
+	t.Run("bulk publish errors should fail the entries without response", func(t *testing.T) {
+		impl := &fakeBulkPublisherImpl{
+			fakePubSubImpl: fakePubSubImpl{featuresResp: []contribPubSub.Feature{contribPubSub.FeatureBulkPublish}},
+			bulkPublishResp: contribPubSub.BulkPublishResponse{FailedEntries: []contribPubSub.BulkPublishResponseFailedEntry{
+				{EntryId: "b", Error: errors.New("fake-entry-err")},
+			}},
+			bulkPublishErr: errors.New("fake-bulk-err"),
+		}
+		ps := &pubsub{getInstance: func(context.Context) (PubSub, error) { return impl, nil }}
+
+		resp, err := ps.BulkPublish(context.Background(), bulkPublishRequest("1", "2", "3"))
+		require.NoError(t, err)
+		assert.Equal(t, []*proto.BulkPublishResponseFailedEntry{
+			{EntryId: "b", Error: "fake-entry-err"},
+			{EntryId: "a", Error: "fake-bulk-err"},
+			{EntryId: "c", Error: "fake-bulk-err"},
+		}, resp.FailedEntries)
+	})
+
+	t.Run("failed entries without error should be reported as failed", func(t *testing.T) {
+		impl := &fakeBulkPublisherImpl{
+			fakePubSubImpl: fakePubSubImpl{featuresResp: []contribPubSub.Feature{contribPubSub.FeatureBulkPublish}},
+			bulkPublishResp: contribPubSub.BulkPublishResponse{FailedEntries: []contribPubSub.BulkPublishResponseFailedEntry{
+				{EntryId: "a"},
+			}},
+		}
+		ps := &pubsub{getInstance: func(context.Context) (PubSub, error) { return impl, nil }}
+
+		resp, err := ps.BulkPublish(context.Background(), bulkPublishRequest("1", "2"))
+		require.NoError(t, err)
+		assert.Equal(t, []*proto.BulkPublishResponseFailedEntry{{EntryId: "a", Error: errBulkPublishFailed.Error()}}, resp.FailedEntries)
+	})
+
+	t.Run("bulk publish should be emulated for pubsubs that don't support it", func(t *testing.T) {
+		impl := &fakeSlowPublisherImpl{}
+		ps := &pubsub{getInstance: func(context.Context) (PubSub, error) { return impl, nil }, opts: newOptions(WithBulkPublishParallelism(3))}
+
+		resp, err := ps.BulkPublish(context.Background(), bulkPublishRequest("1", "fail", "3", "4", "5", "fail", "7", "8"))
+		require.NoError(t, err)
+		assert.Equal(t, []*proto.BulkPublishResponseFailedEntry{
+			{EntryId: "b", Error: "fake-publish-err"},
+			{EntryId: "f", Error: "fake-publish-err"},
+		}, resp.FailedEntries)
+		assert.LessOrEqual(t, impl.maxRunning.Load(), int64(3))
+		assert.Greater(t, impl.maxRunning.Load(), int64(1))
+	})
+
+	t.Run("panics of emulated publishes should fail their entries", func(t *testing.T) {
+		impl := &fakePubSubImpl{onPublishCalled: func(req *contribPubSub.PublishRequest) {
+			if string(req.Data) == "panic" {
+				panic("fake-panic")
+			}
+		}}
+		ps := &pubsub{getInstance: func(context.Context) (PubSub, error) { return impl, nil }}
+
+		resp, err := ps.BulkPublish(context.Background(), bulkPublishRequest("1", "panic"))
+		require.NoError(t, err)
+		require.Len(t, resp.FailedEntries, 1)
+		assert.Equal(t, "b", resp.FailedEntries[0].EntryId)
+		assert.Contains(t, resp.FailedEntries[0].Error, "fake-panic")
+	})
+}
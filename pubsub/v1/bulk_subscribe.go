@@ -0,0 +1,175 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	contribPubSub "github.com/dapr/components-contrib/pubsub"
+)
+
+const (
+	// MaxMessagesCountMetadataKey is the subscription metadata key that enables bulk subscribe with the given maximum number of messages per batch.
+	MaxMessagesCountMetadataKey = "maxMessagesCount"
+	// MaxAwaitDurationMsMetadataKey is the subscription metadata key that enables bulk subscribe with the given maximum time,
+	// in milliseconds, to wait for a batch to fill up.
+	MaxAwaitDurationMsMetadataKey = "maxAwaitDurationMs"
+
+	defaultMaxMessagesCount   = 100
+	defaultMaxAwaitDurationMs = 1000
+)
+
+// bulkHandler returns a bulk handler that sends the entries of each batch in order through the given sender,
+// waits for their acks concurrently and reports the ack status of each of them.
+func bulkHandler(send sendFunc) contribPubSub.BulkHandler {
+	return func(ctx context.Context, msg *contribPubSub.BulkMessage) ([]contribPubSub.BulkSubscribeResponseEntry, error) {
+		statuses := make([]contribPubSub.BulkSubscribeResponseEntry, len(msg.Entries))
+		var wg sync.WaitGroup
+		for idx, entry := range msg.Entries {
+			metadata := make(map[string]string, len(msg.Metadata)+len(entry.Metadata))
+			for k, v := range msg.Metadata {
+				metadata[k] = v
+			}
+			for k, v := range entry.Metadata {
+				metadata[k] = v
+			}
+			contentType := entry.ContentType
+			statuses[idx].EntryId = entry.EntryId
+			wait, err := send(ctx, &contribPubSub.NewMessage{
+				Data:        entry.Event,
+				Topic:       msg.Topic,
+				Metadata:    metadata,
+				ContentType: &contentType,
+			})
+			if err != nil {
+				statuses[idx].Error = err
+				continue
+			}
+			// acks are waited as soon as each entry is sent, so their slots are released when they are bounded.
+			wg.Add(1)
+			go func(idx int) {
+				defer wg.Done()
+				statuses[idx].Error = wait()
+			}(idx)
+		}
+		wg.Wait()
+
+		var errs []error
+		for _, status := range statuses {
+			if status.Error != nil {
+				errs = append(errs, fmt.Errorf("entry %s: %w", status.EntryId, status.Error))
+			}
+		}
+		return statuses, errors.Join(errs...)
+	}
+}
+
+// batchedMessage is a message waiting for its batch to be delivered.
+type batchedMessage struct {
+	msg    *contribPubSub.NewMessage
+	result chan error
+}
+
+// batchingHandler returns a handler that groups the messages it receives into batches delivered through the given bulk handler,
+// for components that don't support bulk subscribe when enabled with WithBulkSubscribeBatching.
+// each call returns the status of its message once its batch is delivered, so components that call the handler serially
+// only deliver a message per batch, every max await duration.
+// batches are flushed when they reach the max messages count or the max await duration after their first message,
+// the batching stops when the given context is done, failing the messages of the pending batch.
+func batchingHandler(ctx context.Context, topic string, cfg contribPubSub.BulkSubscribeConfig, bulk contribPubSub.BulkHandler) contribPubSub.Handler {
+	messages := make(chan batchedMessage)
+	go func() {
+		var (
+			batch    []batchedMessage
+			deadline <-chan time.Time
+			timer    *time.Timer
+		)
+		flush := func() {
+			if timer != nil {
+				timer.Stop()
+				timer, deadline = nil, nil
+			}
+			go deliverBatch(ctx, topic, batch, bulk)
+			batch = nil
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				for _, batched := range batch {
+					batched.result <- ctx.Err()
+				}
+				return
+			case <-deadline:
+				flush()
+			case msg := <-messages:
+				batch = append(batch, msg)
+				if len(batch) == 1 {
+					timer = time.NewTimer(time.Duration(cfg.MaxAwaitDurationMs) * time.Millisecond)
+					deadline = timer.C
+				}
+				if len(batch) >= cfg.MaxMessagesCount {
+					flush()
+				}
+			}
+		}
+	}()
+
+	return func(handlerCtx context.Context, msg *contribPubSub.NewMessage) error {
+		batched := batchedMessage{msg: msg, result: make(chan error, 1)}
+		select {
+		case messages <- batched:
+		case <-handlerCtx.Done():
+			return handlerCtx.Err()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		select {
+		case err := <-batched.result:
+			return err
+		case <-handlerCtx.Done():
+			return handlerCtx.Err()
+		}
+	}
+}
+
+// deliverBatch delivers the given batch through the bulk handler and sends the status of each message back to its caller.
+func deliverBatch(ctx context.Context, topic string, batch []batchedMessage, bulk contribPubSub.BulkHandler) {
+	entries := make([]contribPubSub.BulkMessageEntry, len(batch))
+	for idx, batched := range batch {
+		entries[idx] = contribPubSub.BulkMessageEntry{
+			EntryId:  strconv.Itoa(idx),
+			Event:    batched.msg.Data,
+			Metadata: batched.msg.Metadata,
+		}
+		if batched.msg.ContentType != nil {
+			entries[idx].ContentType = *batched.msg.ContentType
+		}
+	}
+	statuses, err := bulk(ctx, &contribPubSub.BulkMessage{Entries: entries, Topic: topic})
+	for idx, batched := range batch {
+		if idx < len(statuses) {
+			batched.result <- statuses[idx].Error
+		} else {
+			batched.result <- err
+		}
+	}
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	contribPubSub "github.com/dapr/components-contrib/pubsub"
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+
+	sdkerrors "github.com/dapr-sandbox/components-go-sdk/errors"
+	"github.com/dapr-sandbox/components-go-sdk/internal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeBulkSubscriberImpl struct {
+	fakePubSubImpl
+	bulkSubscribeCalled atomic.Int64
+	bulkSubscribeReq    contribPubSub.SubscribeRequest
+	bulkSubscribeErr    error
+}
+
+func (f *fakeBulkSubscriberImpl) BulkSubscribe(_ context.Context, req contribPubSub.SubscribeRequest, _ contribPubSub.BulkHandler) error {
+	f.bulkSubscribeCalled.Add(1)
+	f.bulkSubscribeReq = req
+	return f.bulkSubscribeErr
+}
+
+// fakeBulk records the batches it receives and fails the entries whose event is "fail".
+type fakeBulk struct {
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (f *fakeBulk) handle(_ context.Context, msg *contribPubSub.BulkMessage) ([]contribPubSub.BulkSubscribeResponseEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	batch := make([]string, len(msg.Entries))
+	statuses := make([]contribPubSub.BulkSubscribeResponseEntry, len(msg.Entries))
+	for idx, entry := range msg.Entries {
+		batch[idx] = string(entry.Event)
+		statuses[idx].EntryId = entry.EntryId
+		if batch[idx] == "fail" {
+			statuses[idx].Error = errors.New("fake-err")
+		}
+	}
+	f.batches = append(f.batches, batch)
+	return statuses, nil
+}
+
+func TestBulkSubscribe(t *testing.T) {
+	t.Run("bulk handler should send the entries in order and report their status", func(t *testing.T) {
+		fakeErr := errors.New("fake-err")
+		var sent []*contribPubSub.NewMessage
+		allSent := make(chan struct{})
+		bulk := bulkHandler(func(_ context.Context, msg *contribPubSub.NewMessage) (func() error, error) {
+			sent = append(sent, msg)
+			if len(sent) == 3 {
+				close(allSent)
+			}
+			return func() error {
+				// acks are only settled once every entry is sent, so they must be waited concurrently.
+				<-allSent
+				if string(msg.Data) == "b" {
+					return fakeErr
+				}
+				return nil
+			}, nil
+		})
+
+		statuses, err := bulk(context.Background(), &contribPubSub.BulkMessage{
+			Topic:    "fake-topic",
+			Metadata: map[string]string{"batch": "1"},
+			Entries: []contribPubSub.BulkMessageEntry{
+				{EntryId: "1", Event: []byte("a"), ContentType: "text/plain", Metadata: map[string]string{"entry": "a"}},
+				{EntryId: "2", Event: []byte("b")},
+				{EntryId: "3", Event: []byte("c")},
+			},
+		})
+		assert.ErrorIs(t, err, fakeErr)
+		assert.Equal(t, []contribPubSub.BulkSubscribeResponseEntry{{EntryId: "1"}, {EntryId: "2", Error: fakeErr}, {EntryId: "3"}}, statuses)
+
+		require.Len(t, sent, 3)
+		assert.Equal(t, []string{"a", "b", "c"}, internal.Map(sent, func(msg *contribPubSub.NewMessage) string {
+			return string(msg.Data)
+		}))
+		assert.Equal(t, "fake-topic", sent[0].Topic)
+		assert.Equal(t, "text/plain", *sent[0].ContentType)
+		assert.Equal(t, map[string]string{"batch": "1", "entry": "a"}, sent[0].Metadata)
+	})
+
+	t.Run("bulk handler should report the entries that could not be sent", func(t *testing.T) {
+		fakeErr := errors.New("fake-send-err")
+		bulk := bulkHandler(func(context.Context, *contribPubSub.NewMessage) (func() error, error) {
+			return nil, fakeErr
+		})
+		statuses, err := bulk(context.Background(), &contribPubSub.BulkMessage{Entries: []contribPubSub.BulkMessageEntry{{EntryId: "1"}}})
+		assert.ErrorIs(t, err, fakeErr)
+		assert.Equal(t, []contribPubSub.BulkSubscribeResponseEntry{{EntryId: "1", Error: fakeErr}}, statuses)
+	})
+
+	t.Run("batching handler should flush full batches and return the status of each message", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		bulk := &fakeBulk{}
+		handle := batchingHandler(ctx, "fake-topic", contribPubSub.BulkSubscribeConfig{MaxMessagesCount: 3, MaxAwaitDurationMs: 60_000}, bulk.handle)
+
+		errs := make([]error, 3)
+		var wg sync.WaitGroup
+		for idx, data := range []string{"a", "fail", "c"} {
+			wg.Add(1)
+			go func(idx int, data string) {
+				defer wg.Done()
+				errs[idx] = handle(context.Background(), &contribPubSub.NewMessage{Data: []byte(data)})
+			}(idx, data)
+		}
+		wg.Wait()
+
+		require.Len(t, bulk.batches, 1)
+		assert.ElementsMatch(t, []string{"a", "fail", "c"}, bulk.batches[0])
+		assert.NoError(t, errs[0])
+		assert.Error(t, errs[1])
+		assert.NoError(t, errs[2])
+	})
+
+	t.Run("batching handler should flush partial batches after the max await duration", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		bulk := &fakeBulk{}
+		handle := batchingHandler(ctx, "fake-topic", contribPubSub.BulkSubscribeConfig{MaxMessagesCount: 10, MaxAwaitDurationMs: 10}, bulk.handle)
+
+		assert.NoError(t, handle(context.Background(), &contribPubSub.NewMessage{Data: []byte("a")}))
+		assert.Equal(t, [][]string{{"a"}}, bulk.batches)
+	})
+
+	t.Run("batching handler should fail pending messages when the subscription ends", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		handle := batchingHandler(ctx, "fake-topic", contribPubSub.BulkSubscribeConfig{MaxMessagesCount: 10, MaxAwaitDurationMs: 60_000}, (&fakeBulk{}).handle)
+		time.AfterFunc(10*time.Millisecond, cancel)
+		assert.ErrorIs(t, handle(context.Background(), &contribPubSub.NewMessage{Data: []byte("a")}), context.Canceled)
+	})
+
+	t.Run("subscription metadata should enable bulk subscribe", func(t *testing.T) {
+		opts, err := newOptions().subscriptionFor(nil)
+		require.NoError(t, err)
+		assert.Nil(t, opts.bulkSubscribe)
+
+		opts, err = newOptions().subscriptionFor(map[string]string{MaxMessagesCountMetadataKey: "50"})
+		require.NoError(t, err)
+		assert.Equal(t, &contribPubSub.BulkSubscribeConfig{MaxMessagesCount: 50, MaxAwaitDurationMs: defaultMaxAwaitDurationMs}, opts.bulkSubscribe)
+
+		_, err = newOptions().subscriptionFor(map[string]string{MaxAwaitDurationMsMetadataKey: "soon"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(sdkerrors.ToGRPC(err)))
+	})
+
+	t.Run("pullmessages should bulk subscribe to bulk subscribers", func(t *testing.T) {
+		fakeErr := errors.New("fake-bulk-subscribe-err")
+		impl := &fakeBulkSubscriberImpl{bulkSubscribeErr: fakeErr}
+		ps := &pubsub{
+			subscriptions: NewSubscriptions(),
+			getInstance:   func(_ context.Context) (PubSub, error) { return impl, nil },
+		}
+		recvChan := make(chan *fakeRecvResp, 1)
+		recvChan <- &fakeRecvResp{
+			msg: &proto.PullMessagesRequest{
+				Topic: &proto.Topic{
+					Name:     "fake-topic",
+					Metadata: map[string]string{MaxAwaitDurationMsMetadataKey: "100"},
+				},
+			},
+		}
+		close(recvChan)
+		stream := &fakeStream{
+			recvChan: recvChan,
+		}
+		assert.Equal(t, fakeErr, ps.PullMessages(stream))
+		assert.Equal(t, int64(1), impl.bulkSubscribeCalled.Load())
+		assert.Equal(t, int64(0), impl.subscribeCalled.Load())
+		assert.Equal(t, contribPubSub.BulkSubscribeConfig{MaxMessagesCount: defaultMaxMessagesCount, MaxAwaitDurationMs: 100}, impl.bulkSubscribeReq.BulkSubscribeConfig)
+	})
+
+	t.Run("pullmessages should deliver messages one at a time to other pubsubs unless batching is enabled", func(t *testing.T) {
+		handlerResp := make(chan error, 1)
+		impl := &fakePubSubImpl{
+			subscribeChan: make(chan *contribPubSub.NewMessage, 1),
+			subscribeCtx:  context.Background(),
+			onHandlerResp: func(err error) {
+				handlerResp <- err
+			},
+		}
+		defer close(impl.subscribeChan)
+		ps := &pubsub{
+			subscriptions: NewSubscriptions(),
+			getInstance:   func(_ context.Context) (PubSub, error) { return impl, nil },
+		}
+		recvChan := make(chan *fakeRecvResp, 2)
+		recvChan <- &fakeRecvResp{
+			msg: &proto.PullMessagesRequest{
+				Topic: &proto.Topic{
+					Name:     "fake-topic",
+					Metadata: map[string]string{MaxAwaitDurationMsMetadataKey: "60000"},
+				},
+			},
+		}
+		stream := &fakeStream{
+			recvChan: recvChan,
+			onSendCalled: func(msg *proto.PullMessagesResponse) {
+				recvChan <- &fakeRecvResp{msg: &proto.PullMessagesRequest{AckMessageId: msg.Id}}
+			},
+		}
+		pullErr := make(chan error, 1)
+		go func() {
+			pullErr <- ps.PullMessages(stream)
+		}()
+
+		impl.subscribeChan <- &contribPubSub.NewMessage{Topic: "fake-topic"}
+		select {
+		case err := <-handlerResp:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("message was not delivered on its own")
+		}
+
+		recvChan <- &fakeRecvResp{err: io.EOF}
+		assert.NoError(t, <-pullErr)
+	})
+}
@@ -0,0 +1,33 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pubsub
+
+import (
+	"github.com/dapr-sandbox/components-go-sdk/internal"
+)
+
+// Codec encodes and decodes the values of a content type.
+type Codec = internal.Codec
+
+// RegisterCodec registers the codec of the given content type, so components can decode and encode message payloads using CodecFor.
+// Content types with a structured syntax suffix, such as application/merge-patch+json, use the codec of their suffix
+// unless they have one of their own. Codecs are shared with the state package.
+func RegisterCodec(contentType string, codec Codec) {
+	internal.Codecs.Register(contentType, codec)
+}
+
+// CodecFor returns the codec registered for the given content type.
+func CodecFor(contentType string) (Codec, bool) {
+	return internal.Codecs.Lookup(contentType)
+}
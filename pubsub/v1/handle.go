@@ -16,6 +16,7 @@ package pubsub
 import (
 	"context"
 	"io"
+	"time"
 
 	contribPubSub "github.com/dapr/components-contrib/pubsub"
 	proto "github.com/dapr/dapr/pkg/proto/components/v1"
@@ -47,17 +48,50 @@ func ackLoop(streamCtx context.Context, tfStream internal.ThreadSafeStream[proto
 		if ack.AckError != nil {
 			ackError = errors.New(ack.AckError.Message)
 		}
-		if err := ackManager.Ack(ack.AckMessageId, ackError); err != nil {
+		err = ackManager.Ack(ack.AckMessageId, ackError)
+		switch {
+		case errors.Is(err, internal.ErrLateAck):
+			pubsubLogger.Warnf("message %s was acked after its deadline and has been nacked, %d late acks so far", ack.AckMessageId, ackManager.LateAcks())
+		case err != nil:
 			pubsubLogger.Warnf("error %v when trying to notify ack", err)
 		}
 	}
 }
 
-// handler build a pubsub handler using the given threadsafe stream and the ack manager.
-func handler(tfStream internal.ThreadSafeStream[proto.PullMessagesResponse, proto.PullMessagesRequest], ackManager *internal.AcknowledgementManager[error]) contribPubSub.Handler {
-	return func(ctx context.Context, contribMsg *contribPubSub.NewMessage) error {
-		msgID, pendingAck, cleanup := ackManager.Get()
-		defer cleanup()
+// sendFunc sends a message to daprd and returns a function that waits for its ack.
+type sendFunc func(ctx context.Context, msg *contribPubSub.NewMessage) (wait func() error, err error)
+
+// handle sends the message and waits for its ack.
+func (send sendFunc) handle(ctx context.Context, msg *contribPubSub.NewMessage) error {
+	wait, err := send(ctx, msg)
+	if err != nil {
+		return err
+	}
+	return wait()
+}
+
+// handler build a pubsub handler using the given threadsafe stream and the ack manager for the given subscription.
+// it waits for a free slot when the ack manager is bounded, and messages that are not acked
+// within the ack timeout, when greater than zero, are nacked with ErrAckTimeout.
+// messages delivered or pending when the subscription ends are nacked with ErrSubscriptionEnded.
+func handler(tfStream internal.ThreadSafeStream[proto.PullMessagesResponse, proto.PullMessagesRequest], ackManager *internal.AcknowledgementManager[error], ackTimeout time.Duration, sub *subscription) contribPubSub.Handler {
+	return sender(tfStream, ackManager, ackTimeout, sub).handle
+}
+
+// sender is like handler but splits the delivery of each message from the wait for its ack,
+// the returned wait function must be called once the message is sent so its slot is released.
+func sender(tfStream internal.ThreadSafeStream[proto.PullMessagesResponse, proto.PullMessagesRequest], ackManager *internal.AcknowledgementManager[error], ackTimeout time.Duration, sub *subscription) sendFunc {
+	return func(ctx context.Context, contribMsg *contribPubSub.NewMessage) (func() error, error) {
+		msgID, pendingAck, cleanup, err := ackManager.Acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if sub.ended() {
+			cleanup()
+			pubsubLogger.Warnf("dropping message delivered on topic %s after its subscription %s has ended", contribMsg.Topic, sub.id)
+			return nil, ErrSubscriptionEnded
+		}
 
 		msg := &proto.PullMessagesResponse{
 			Data:        contribMsg.Data,
@@ -71,25 +105,70 @@ func handler(tfStream internal.ThreadSafeStream[proto.PullMessagesResponse, prot
 		// it only means that the component wasn't able to receive the response back
 		// it could leads in messages being acknowledged without even being pending first
 		// we should ignore this since it will be probably retried by the underlying component.
-		err := tfStream.Send(msg)
+		err = tfStream.Send(msg)
 		if err != nil {
-			return errors.Wrapf(err, "error when sending message %s to consumer on topic %s", msg.Id, msg.TopicName)
+			cleanup()
+			return nil, errors.Wrapf(err, "error when sending message %s to consumer on topic %s", msg.Id, msg.TopicName)
 		}
+		sub.delivered.Add(1)
 
-		select {
-		case err := <-pendingAck:
-			return err
-		case <-ctx.Done():
-			return ErrAckTimeout
+		var deadline <-chan time.Time
+		var stop func() bool
+		if ackTimeout > 0 {
+			timer := time.NewTimer(ackTimeout)
+			deadline, stop = timer.C, timer.Stop
 		}
+
+		return func() error {
+			defer cleanup()
+			if stop != nil {
+				defer stop()
+			}
+
+			select {
+			case err := <-pendingAck:
+				if err != nil {
+					sub.nacked.Add(1)
+				} else {
+					sub.acked.Add(1)
+				}
+				return err
+			case <-deadline:
+				ackManager.Expire(msgID)
+				sub.nacked.Add(1)
+				return ErrAckTimeout
+			case <-sub.done:
+				sub.nacked.Add(1)
+				return ErrSubscriptionEnded
+			case <-ctx.Done():
+				sub.nacked.Add(1)
+				return ErrAckTimeout
+			}
+		}, nil
 	}
 }
 
-// pullFor creates a message handler for the given stream.
-func pullFor(stream proto.PubSub_PullMessagesServer) (pubsubHandler contribPubSub.Handler, acknLoop func() error) {
+// pullFor creates a message sender for the given stream and subscription.
+// the sender stops handing new messages when the server is shutting down, and the returned ack loop
+// keeps receiving acks until all pending ones are settled, the component should be canceled once it returns.
+func pullFor(stream proto.PubSub_PullMessagesServer, opts options, sub *subscription) (send sendFunc, acknLoop func() error) {
 	tfStream := internal.NewGRPCThreadSafeStream[proto.PullMessagesResponse, proto.PullMessagesRequest](stream)
-	ackManager := internal.NewAckManager[error]()
-	return handler(tfStream, ackManager), func() error {
-		return ackLoop(stream.Context(), tfStream, ackManager)
+	ackManager := internal.NewBoundedAckManager[error](opts.maxConcurrentMessages)
+	sub.acks.Store(ackManager)
+	shutdown := internal.ShutdownFromContext(stream.Context())
+	sendMsg := sender(tfStream, ackManager, opts.ackTimeout, sub)
+	send = func(ctx context.Context, msg *contribPubSub.NewMessage) (wait func() error, err error) {
+		defer internal.Recover(stream.Context(), pubsubLogger, &err)
+		if internal.IsShuttingDown(shutdown) {
+			return nil, internal.ErrShuttingDown
+		}
+		return sendMsg(ctx, msg)
+	}
+	acknLoop = func() error {
+		return internal.DrainAcks(shutdown, func() (err error) {
+			defer internal.Recover(stream.Context(), pubsubLogger, &err)
+			return ackLoop(stream.Context(), tfStream, ackManager)
+		}, ackManager)
 	}
+	return send, acknLoop
 }
@@ -20,6 +20,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	contribPubSub "github.com/dapr/components-contrib/pubsub"
 	proto "github.com/dapr/dapr/pkg/proto/components/v1"
@@ -27,6 +28,8 @@ import (
 	"github.com/dapr-sandbox/components-go-sdk/internal"
 
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type recvFakeResp struct {
@@ -128,7 +131,7 @@ func TestHandler(t *testing.T) {
 		sendErr := errors.New("fake-err")
 		stream := &fakeTSStream{sendErr: sendErr}
 		acks := internal.NewAckManager[error]()
-		handlerf := handler(stream, acks)
+		handlerf := handler(stream, acks, 0, newSubscription("", "", nil))
 
 		assert.NotNil(t, handlerf(context.TODO(), &contribPubSub.NewMessage{}))
 		assert.Empty(t, acks.Pending())
@@ -137,7 +140,7 @@ func TestHandler(t *testing.T) {
 	t.Run("handle should return Acktimeout when context is done", func(t *testing.T) {
 		stream := &fakeTSStream{}
 		acks := internal.NewAckManager[error]()
-		handlerf := handler(stream, acks)
+		handlerf := handler(stream, acks, 0, newSubscription("", "", nil))
 		ctx, cancel := context.WithCancel(context.Background())
 		cancel()
 
@@ -155,7 +158,7 @@ func TestHandler(t *testing.T) {
 			},
 		}
 		acks := internal.NewAckManager[error]()
-		handlerf := handler(stream, acks)
+		handlerf := handler(stream, acks, 0, newSubscription("", "", nil))
 		go func() {
 			sendCalledWg.Wait()
 			for _, pendingAck := range acks.Pending() {
@@ -167,4 +170,61 @@ func TestHandler(t *testing.T) {
 		assert.Empty(t, acks.Pending())
 		assert.Equal(t, int64(1), stream.sendCalled.Load())
 	})
+	t.Run("handle should wait for a free slot when the in-flight messages are bounded", func(t *testing.T) {
+		sent := make(chan struct{}, 2)
+		stream := &fakeTSStream{
+			onSendCalled: func(*proto.PullMessagesResponse) {
+				sent <- struct{}{}
+			},
+		}
+		acks := internal.NewBoundedAckManager[error](1)
+		handlerf := handler(stream, acks, 0, newSubscription("", "", nil))
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			assert.NoError(t, handlerf(context.Background(), &contribPubSub.NewMessage{}))
+		}()
+		<-sent
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, handlerf(ctx, &contribPubSub.NewMessage{}), context.DeadlineExceeded)
+		assert.Len(t, acks.Pending(), 1)
+		assert.Equal(t, int64(1), stream.sendCalled.Load())
+
+		for _, pendingAck := range acks.Pending() {
+			pendingAck <- nil
+		}
+		<-done
+		assert.Empty(t, acks.Pending())
+	})
+	t.Run("handle should return AckTimeout when the ack timeout expires and report the late ack", func(t *testing.T) {
+		var msgID string
+		stream := &fakeTSStream{
+			onSendCalled: func(msg *proto.PullMessagesResponse) {
+				msgID = msg.Id
+			},
+		}
+		acks := internal.NewAckManager[error]()
+		handlerf := handler(stream, acks, 10*time.Millisecond, newSubscription("", "", nil))
+
+		assert.Equal(t, ErrAckTimeout, handlerf(context.Background(), &contribPubSub.NewMessage{}))
+		assert.Empty(t, acks.Pending())
+		assert.ErrorIs(t, acks.Ack(msgID, nil), internal.ErrLateAck)
+		assert.Equal(t, int64(1), acks.LateAcks())
+	})
+}
+
+func TestPullFor(t *testing.T) {
+	t.Run("handler should recover from panics and return an internal error", func(t *testing.T) {
+		stream := &fakeStream{
+			onSendCalled: func(*proto.PullMessagesResponse) {
+				panic("fake-panic")
+			},
+		}
+		send, _ := pullFor(stream, options{}, newSubscription("", "", nil))
+
+		err := send.handle(context.Background(), &contribPubSub.NewMessage{})
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
 }
@@ -0,0 +1,155 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pubsub
+
+import (
+	"strconv"
+	"time"
+
+	contribPubSub "github.com/dapr/components-contrib/pubsub"
+
+	sdkerrors "github.com/dapr-sandbox/components-go-sdk/errors"
+)
+
+const (
+	// AckTimeoutMetadataKey is the subscription metadata key that overrides the ack timeout of its messages, as a duration such as "30s".
+	AckTimeoutMetadataKey = "ackTimeout"
+	// MaxConcurrentMessagesMetadataKey is the subscription metadata key that overrides the maximum number of its in-flight messages.
+	MaxConcurrentMessagesMetadataKey = "maxConcurrentMessages"
+)
+
+// Option configures how the pubsub is served.
+type Option func(*options)
+
+type options struct {
+	// ackTimeout is the time daprd has to ack each message, zero or less waits until the subscription ends.
+	ackTimeout time.Duration
+	// maxConcurrentMessages is the maximum number of messages waiting for their ack on each subscription, zero or less is unlimited.
+	maxConcurrentMessages int
+	// bulkSubscribe is the bulk subscribe config of the subscription, nil when it is not enabled.
+	bulkSubscribe *contribPubSub.BulkSubscribeConfig
+	// bulkSubscribeBatching enables batching the messages of the components that don't support bulk subscribe.
+	bulkSubscribeBatching bool
+	// subscriptions tracks the subscriptions of the registered pubsub, a new registry is used when nil.
+	subscriptions *Subscriptions
+	// bulkPublishParallelism is the number of concurrent publishes of emulated bulk publishes, zero or less is unlimited.
+	bulkPublishParallelism int
+}
+
+func newOptions(opts ...Option) options {
+	o := options{
+		bulkPublishParallelism: defaultBulkPublishParallelism,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithAckTimeout sets the time daprd has to ack each message delivered to it, messages that are not acked by then
+// are nacked with ErrAckTimeout so the broker can redeliver them. Zero or less, the default, waits until the subscription ends.
+// Subscriptions can override it through the ackTimeout metadata.
+func WithAckTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.ackTimeout = timeout
+	}
+}
+
+// WithMaxConcurrentMessages bounds the messages of each subscription that are waiting for their ack,
+// the subscription handler blocks until acks free a slot. Zero or less, the default, is unlimited.
+// Subscriptions can override it through the maxConcurrentMessages metadata.
+func WithMaxConcurrentMessages(n int) Option {
+	return func(o *options) {
+		o.maxConcurrentMessages = n
+	}
+}
+
+// WithBulkPublishParallelism sets the number of concurrent publishes used to emulate bulk publishes for the pubsubs
+// that don't support them natively, 10 by default. Zero or less publishes all of the entries concurrently.
+func WithBulkPublishParallelism(parallelism int) Option {
+	return func(o *options) {
+		o.bulkPublishParallelism = parallelism
+	}
+}
+
+// WithBulkSubscribeBatching enables bulk subscribe for the pubsubs that don't support it natively: the messages delivered
+// to the subscription handler are grouped into batches, which are sent to daprd once full or after the max await duration.
+// Each handler call blocks until the batch of its message is acked, so it only suits components that deliver messages
+// concurrently: the ones that call the handler serially deliver a single message per batch, every max await duration.
+// By default, their bulk subscriptions are served one message at a time.
+func WithBulkSubscribeBatching() Option {
+	return func(o *options) {
+		o.bulkSubscribeBatching = true
+	}
+}
+
+// WithSubscriptions tracks the subscriptions of the registered pubsub in the given registry, so they can be inspected
+// when the pubsub is registered through the dapr package. A registry should not be shared between registrations.
+func WithSubscriptions(subscriptions *Subscriptions) Option {
+	return func(o *options) {
+		o.subscriptions = subscriptions
+	}
+}
+
+// subscriptionFor returns the options of the subscription with the given metadata,
+// which override the registration ones.
+func (o options) subscriptionFor(metadata map[string]string) (options, error) {
+	if value, ok := metadata[AckTimeoutMetadataKey]; ok {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return o, sdkerrors.InvalidArgument("invalid %s metadata %q: %v", AckTimeoutMetadataKey, value, err)
+		}
+		o.ackTimeout = timeout
+	}
+	if n, ok, err := intMetadata(metadata, MaxConcurrentMessagesMetadataKey); err != nil {
+		return o, err
+	} else if ok {
+		o.maxConcurrentMessages = n
+	}
+
+	maxMessagesCount, hasMaxMessagesCount, err := intMetadata(metadata, MaxMessagesCountMetadataKey)
+	if err != nil {
+		return o, err
+	}
+	maxAwaitDurationMs, hasMaxAwaitDurationMs, err := intMetadata(metadata, MaxAwaitDurationMsMetadataKey)
+	if err != nil {
+		return o, err
+	}
+	if hasMaxMessagesCount || hasMaxAwaitDurationMs {
+		o.bulkSubscribe = &contribPubSub.BulkSubscribeConfig{
+			MaxMessagesCount:   defaultMaxMessagesCount,
+			MaxAwaitDurationMs: defaultMaxAwaitDurationMs,
+		}
+		if maxMessagesCount > 0 {
+			o.bulkSubscribe.MaxMessagesCount = maxMessagesCount
+		}
+		if maxAwaitDurationMs > 0 {
+			o.bulkSubscribe.MaxAwaitDurationMs = maxAwaitDurationMs
+		}
+	}
+	return o, nil
+}
+
+// intMetadata parses the integer metadata with the given key, if present.
+func intMetadata(metadata map[string]string, key string) (int, bool, error) {
+	value, ok := metadata[key]
+	if !ok {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false, sdkerrors.InvalidArgument("invalid %s metadata %q: %v", key, value, err)
+	}
+	return n, true, nil
+}
@@ -27,3 +27,9 @@ type PubSub interface {
 type BulkPublisher interface {
 	contribPubSub.BulkPublisher
 }
+
+// BulkSubscriber is implemented by the pubsubs that can deliver messages in batches,
+// it is used for the subscriptions that enable bulk subscribe.
+type BulkSubscriber interface {
+	contribPubSub.BulkSubscriber
+}
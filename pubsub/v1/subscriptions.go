@@ -0,0 +1,189 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pubsub
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/dapr-sandbox/components-go-sdk/internal"
+)
+
+// ErrSubscriptionEnded is returned to the component for the messages it delivers after their subscription has ended,
+// which are dropped without reaching daprd.
+var ErrSubscriptionEnded = errors.New("subscription has ended")
+
+// Subscription describes an active subscription, that is a PullMessages stream opened by daprd.
+type Subscription struct {
+	// ID identifies the subscription.
+	ID string
+	// InstanceID is the ID of the component instance, empty for the default instance.
+	InstanceID string
+	// Topic is the subscribed topic.
+	Topic string
+	// Metadata is the subscription metadata.
+	Metadata map[string]string
+	// StartedAt is when the stream was opened.
+	StartedAt time.Time
+	// Delivered is the number of messages sent to daprd.
+	Delivered int64
+	// Acked is the number of messages acked by daprd.
+	Acked int64
+	// Nacked is the number of messages acked by daprd with an error or that were not acked in time.
+	Nacked int64
+	// LateAcks is the number of acks received from daprd after their message was nacked for not being acked in time.
+	LateAcks int64
+	// InFlight is the number of messages sent to daprd that are waiting for their ack.
+	InFlight int
+}
+
+// subscription tracks an active subscription of a pubsub instance.
+type subscription struct {
+	id         string
+	instanceID string
+	topic      string
+	metadata   map[string]string
+	startedAt  time.Time
+	delivered  atomic.Int64
+	acked      atomic.Int64
+	nacked     atomic.Int64
+	// acks is the ack manager of the subscription stream, nil until the stream starts pulling messages.
+	acks atomic.Pointer[internal.AcknowledgementManager[error]]
+	// done is closed when the subscription ends.
+	done chan struct{}
+}
+
+func newSubscription(instanceID string, topic string, metadata map[string]string) *subscription {
+	return &subscription{
+		id:         uuid.New().String(),
+		instanceID: instanceID,
+		topic:      topic,
+		metadata:   metadata,
+		startedAt:  time.Now(),
+		done:       make(chan struct{}),
+	}
+}
+
+// ended returns whether the subscription has ended.
+func (s *subscription) ended() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *subscription) info() Subscription {
+	var metadata map[string]string
+	if s.metadata != nil {
+		metadata = make(map[string]string, len(s.metadata))
+		for k, v := range s.metadata {
+			metadata[k] = v
+		}
+	}
+	return Subscription{
+		ID:         s.id,
+		InstanceID: s.instanceID,
+		Topic:      s.topic,
+		Metadata:   metadata,
+		StartedAt:  s.startedAt,
+		Delivered:  s.delivered.Load(),
+		Acked:      s.acked.Load(),
+		Nacked:     s.nacked.Load(),
+		LateAcks:   s.lateAcks(),
+		InFlight:   s.inFlight(),
+	}
+}
+
+// lateAcks returns the number of acks received after the deadline of their message.
+func (s *subscription) lateAcks() int64 {
+	acks := s.acks.Load()
+	if acks == nil {
+		return 0
+	}
+	return acks.LateAcks()
+}
+
+// inFlight returns the number of messages waiting for their ack.
+func (s *subscription) inFlight() int {
+	acks := s.acks.Load()
+	if acks == nil {
+		return 0
+	}
+	return len(acks.Pending())
+}
+
+// Subscriptions holds the active subscriptions of the instances of a registered pubsub.
+type Subscriptions struct {
+	mu            sync.RWMutex
+	subscriptions map[string]*subscription
+}
+
+// NewSubscriptions returns an empty subscriptions registry, to be given to a single registration through WithSubscriptions.
+func NewSubscriptions() *Subscriptions {
+	return &Subscriptions{subscriptions: make(map[string]*subscription)}
+}
+
+// add starts tracking a new subscription of the given instance, end should be called when the subscription ends.
+func (r *Subscriptions) add(instanceID string, topic string, metadata map[string]string) *subscription {
+	sub := newSubscription(instanceID, topic, metadata)
+	r.mu.Lock()
+	r.subscriptions[sub.id] = sub
+	r.mu.Unlock()
+	return sub
+}
+
+// end ends the given subscription and stops tracking it.
+func (r *Subscriptions) end(sub *subscription) {
+	r.mu.Lock()
+	delete(r.subscriptions, sub.id)
+	r.mu.Unlock()
+	close(sub.done)
+}
+
+// list returns the active subscriptions that match the given filter, ordered by start time.
+func (r *Subscriptions) list(filter func(*subscription) bool) []Subscription {
+	r.mu.RLock()
+	infos := make([]Subscription, 0, len(r.subscriptions))
+	for _, sub := range r.subscriptions {
+		if filter(sub) {
+			infos = append(infos, sub.info())
+		}
+	}
+	r.mu.RUnlock()
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartedAt.Before(infos[j].StartedAt)
+	})
+	return infos
+}
+
+// Instance returns the active subscriptions of the instance with the given ID, which is empty for the default instance.
+func (r *Subscriptions) Instance(instanceID string) []Subscription {
+	return r.list(func(sub *subscription) bool {
+		return sub.instanceID == instanceID
+	})
+}
+
+// All returns the active subscriptions of every instance.
+func (r *Subscriptions) All() []Subscription {
+	return r.list(func(*subscription) bool {
+		return true
+	})
+}
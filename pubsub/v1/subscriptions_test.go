@@ -0,0 +1,197 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	contribPubSub "github.com/dapr/components-contrib/pubsub"
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+
+	"github.com/dapr-sandbox/components-go-sdk/internal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestSubscriptions(t *testing.T) {
+	t.Run("subscriptions should be listed by instance until they end", func(t *testing.T) {
+		subscriptions := NewSubscriptions()
+		subA := subscriptions.add("instance-a", "topic-a", map[string]string{"k": "v"})
+		subB := subscriptions.add("", "topic-b", nil)
+		defer subscriptions.end(subB)
+
+		subs := subscriptions.Instance("instance-a")
+		require.Len(t, subs, 1)
+		assert.Equal(t, subA.id, subs[0].ID)
+		assert.Equal(t, "instance-a", subs[0].InstanceID)
+		assert.Equal(t, "topic-a", subs[0].Topic)
+		assert.Equal(t, map[string]string{"k": "v"}, subs[0].Metadata)
+		assert.ElementsMatch(t, []string{"topic-a", "topic-b"}, topicsOf(subscriptions.All()))
+		assert.Equal(t, []string{"topic-b"}, topicsOf(subscriptions.Instance("")))
+
+		subscriptions.end(subA)
+		assert.Empty(t, subscriptions.Instance("instance-a"))
+		assert.True(t, subA.ended())
+		assert.Equal(t, []string{"topic-b"}, topicsOf(subscriptions.All()))
+	})
+
+	t.Run("listed metadata should be a copy", func(t *testing.T) {
+		subscriptions := NewSubscriptions()
+		sub := subscriptions.add("", "topic", map[string]string{"k": "v"})
+		defer subscriptions.end(sub)
+
+		subscriptions.All()[0].Metadata["k"] = "changed"
+		assert.Equal(t, map[string]string{"k": "v"}, subscriptions.All()[0].Metadata)
+	})
+
+	t.Run("handler should count the delivered, acked and nacked messages", func(t *testing.T) {
+		acks := internal.NewAckManager[error]()
+		sub := newSubscription("", "", nil)
+		stream := &fakeTSStream{
+			onSendCalled: func(msg *proto.PullMessagesResponse) {
+				var ackErr error
+				if string(msg.Data) == "fail" {
+					ackErr = errors.New("fake-err")
+				}
+				go func() {
+					assert.NoError(t, acks.Ack(msg.Id, ackErr))
+				}()
+			},
+		}
+		handlerf := handler(stream, acks, 0, sub)
+
+		assert.NoError(t, handlerf(context.Background(), &contribPubSub.NewMessage{Data: []byte("ok")}))
+		assert.Error(t, handlerf(context.Background(), &contribPubSub.NewMessage{Data: []byte("fail")}))
+		info := sub.info()
+		assert.Equal(t, int64(2), info.Delivered)
+		assert.Equal(t, int64(1), info.Acked)
+		assert.Equal(t, int64(1), info.Nacked)
+	})
+
+	t.Run("messages delivered after the subscription ends should be dropped", func(t *testing.T) {
+		stream := &fakeTSStream{}
+		acks := internal.NewAckManager[error]()
+		subscriptions := NewSubscriptions()
+		sub := subscriptions.add("", "", nil)
+		subscriptions.end(sub)
+
+		assert.Equal(t, ErrSubscriptionEnded, handler(stream, acks, 0, sub)(context.Background(), &contribPubSub.NewMessage{}))
+		assert.Equal(t, int64(0), stream.sendCalled.Load())
+		assert.Empty(t, acks.Pending())
+	})
+
+	t.Run("pending messages should be nacked when the subscription ends", func(t *testing.T) {
+		subscriptions := NewSubscriptions()
+		sub := subscriptions.add("", "", nil)
+		stream := &fakeTSStream{
+			onSendCalled: func(*proto.PullMessagesResponse) {
+				go subscriptions.end(sub)
+			},
+		}
+		acks := internal.NewAckManager[error]()
+
+		assert.Equal(t, ErrSubscriptionEnded, handler(stream, acks, 0, sub)(context.Background(), &contribPubSub.NewMessage{}))
+		assert.Equal(t, int64(1), sub.info().Nacked)
+	})
+
+	t.Run("pullmessages should track its subscription while the stream is open", func(t *testing.T) {
+		var during []Subscription
+		subscriptions := NewSubscriptions()
+		impl := &fakePubSubImpl{}
+		impl.onSubscribeCalled = func(contribPubSub.SubscribeRequest) {
+			during = subscriptions.Instance("fake-instance")
+		}
+		ps := &pubsub{
+			getInstance:   func(_ context.Context) (PubSub, error) { return impl, nil },
+			subscriptions: subscriptions,
+		}
+		recvChan := make(chan *fakeRecvResp, 2)
+		recvChan <- &fakeRecvResp{
+			msg: &proto.PullMessagesRequest{
+				Topic: &proto.Topic{Name: "fake-topic"},
+			},
+		}
+		recvChan <- &fakeRecvResp{err: io.EOF}
+		close(recvChan)
+
+		stream := &fakeStream{recvChan: recvChan, ctx: internal.WithInstance(context.Background(), "fake-instance", "fake-name")}
+		assert.NoError(t, ps.PullMessages(stream))
+		require.Len(t, during, 1)
+		assert.Equal(t, "fake-topic", during[0].Topic)
+		assert.Empty(t, subscriptions.All())
+	})
+
+	t.Run("in flight messages should be reported until they are acked", func(t *testing.T) {
+		var msgID string
+		stream := &fakeStream{
+			onSendCalled: func(msg *proto.PullMessagesResponse) {
+				msgID = msg.Id
+			},
+		}
+		subscriptions := NewSubscriptions()
+		sub := subscriptions.add("", "topic", nil)
+		defer subscriptions.end(sub)
+		send, _ := pullFor(stream, options{}, sub)
+		assert.Equal(t, 0, subscriptions.All()[0].InFlight)
+
+		wait, err := send(context.Background(), &contribPubSub.NewMessage{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, subscriptions.All()[0].InFlight)
+
+		require.NoError(t, sub.acks.Load().Ack(msgID, nil))
+		assert.NoError(t, wait())
+		assert.Equal(t, 0, subscriptions.All()[0].InFlight)
+	})
+
+	t.Run("acks received after the ack timeout should be reported as late", func(t *testing.T) {
+		var msgID string
+		stream := &fakeStream{
+			onSendCalled: func(msg *proto.PullMessagesResponse) {
+				msgID = msg.Id
+			},
+		}
+		subscriptions := NewSubscriptions()
+		sub := subscriptions.add("", "topic", nil)
+		defer subscriptions.end(sub)
+		send, _ := pullFor(stream, options{ackTimeout: 10 * time.Millisecond}, sub)
+
+		wait, err := send(context.Background(), &contribPubSub.NewMessage{})
+		require.NoError(t, err)
+		assert.Equal(t, ErrAckTimeout, wait())
+		assert.Equal(t, int64(0), subscriptions.All()[0].LateAcks)
+
+		assert.ErrorIs(t, sub.acks.Load().Ack(msgID, nil), internal.ErrLateAck)
+		info := subscriptions.All()[0]
+		assert.Equal(t, int64(1), info.LateAcks)
+		assert.Equal(t, int64(1), info.Nacked)
+	})
+
+	t.Run("registrations should use the given registry", func(t *testing.T) {
+		subscriptions := NewSubscriptions()
+		assert.Same(t, subscriptions, Register(grpc.NewServer(), nil, WithSubscriptions(subscriptions)))
+		assert.NotSame(t, subscriptions, Register(grpc.NewServer(), nil))
+	})
+}
+
+func topicsOf(subs []Subscription) []string {
+	return internal.Map(subs, func(sub Subscription) string {
+		return sub.Topic
+	})
+}
@@ -21,6 +21,7 @@ import (
 	proto "github.com/dapr/dapr/pkg/proto/components/v1"
 	"github.com/dapr/kit/logger"
 
+	sdkerrors "github.com/dapr-sandbox/components-go-sdk/errors"
 	"github.com/dapr-sandbox/components-go-sdk/internal"
 
 	"google.golang.org/grpc"
@@ -35,7 +36,9 @@ var (
 
 type pubsub struct {
 	proto.UnimplementedPubSubServer
-	getInstance func(context.Context) PubSub
+	getInstance   func(context.Context) (PubSub, error)
+	opts          options
+	subscriptions *Subscriptions
 }
 
 // Establishes a stream with the server, which sends messages down to the
@@ -56,32 +59,64 @@ func (s *pubsub) PullMessages(stream proto.PubSub_PullMessagesServer) error {
 		return ErrTopicNotSpecified
 	}
 
+	opts, err := s.opts.subscriptionFor(topic.Metadata)
+	if err != nil {
+		return sdkerrors.ToGRPC(err)
+	}
+
 	ctx, cancel := context.WithCancel(stream.Context())
 	defer cancel()
 
-	handler, startAckLoop := pullFor(stream)
+	instance, err := s.getInstance(ctx)
+	if err != nil {
+		return err
+	}
+
+	sub := s.subscriptions.add(internal.InstanceIDFromContext(ctx), topic.Name, topic.Metadata)
+	defer s.subscriptions.end(sub)
+
+	send, startAckLoop := pullFor(stream, opts, sub)
 
-	err = s.getInstance(ctx).Subscribe(ctx, contribPubSub.SubscribeRequest{
+	req := contribPubSub.SubscribeRequest{
 		Topic:    topic.Name,
 		Metadata: topic.Metadata,
-	}, handler)
+	}
+	switch bulkSubscriber, isBulkSubscriber := instance.(BulkSubscriber); {
+	case opts.bulkSubscribe == nil:
+		err = instance.Subscribe(ctx, req, send.handle)
+	case isBulkSubscriber:
+		req.BulkSubscribeConfig = *opts.bulkSubscribe
+		err = bulkSubscriber.BulkSubscribe(ctx, req, bulkHandler(send))
+	case opts.bulkSubscribeBatching:
+		err = instance.Subscribe(ctx, req, batchingHandler(ctx, topic.Name, *opts.bulkSubscribe, bulkHandler(send)))
+	default:
+		err = instance.Subscribe(ctx, req, send.handle)
+	}
 
 	if err != nil {
-		return err
+		return sdkerrors.ToGRPC(err)
 	}
 
 	return startAckLoop()
 }
 
 func (s *pubsub) Init(ctx context.Context, initReq *proto.PubSubInitRequest) (*proto.PubSubInitResponse, error) {
-	return &proto.PubSubInitResponse{}, s.getInstance(ctx).Init(ctx, contribPubSub.Metadata{
+	instance, err := s.getInstance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.PubSubInitResponse{}, sdkerrors.ToGRPC(instance.Init(ctx, contribPubSub.Metadata{
 		Base: contribMetadata.Base{Properties: initReq.Metadata.Properties},
-	})
+	}))
 }
 
 func (s *pubsub) Features(ctx context.Context, _ *proto.FeaturesRequest) (*proto.FeaturesResponse, error) {
+	instance, err := s.getInstance(ctx)
+	if err != nil {
+		return nil, err
+	}
 	features := &proto.FeaturesResponse{
-		Features: internal.Map(s.getInstance(ctx).Features(), func(f contribPubSub.Feature) string {
+		Features: internal.Map(instance.Features(), func(f contribPubSub.Feature) string {
 			return string(f)
 		}),
 	}
@@ -89,63 +124,82 @@ func (s *pubsub) Features(ctx context.Context, _ *proto.FeaturesRequest) (*proto
 }
 
 func (s *pubsub) Publish(ctx context.Context, req *proto.PublishRequest) (*proto.PublishResponse, error) {
-	return &proto.PublishResponse{}, s.getInstance(ctx).Publish(ctx, &contribPubSub.PublishRequest{
+	instance, err := s.getInstance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.PublishResponse{}, sdkerrors.ToGRPC(instance.Publish(ctx, &contribPubSub.PublishRequest{
 		Data:        req.Data,
 		PubsubName:  req.PubsubName,
 		Topic:       req.Topic,
 		Metadata:    req.Metadata,
 		ContentType: &req.ContentType,
-	})
+	}))
 }
 
 func (s *pubsub) BulkPublish(ctx context.Context, req *proto.BulkPublishRequest) (*proto.BulkPublishResponse, error) {
-	bulkPublisherInstance, ok := s.getInstance(ctx).(BulkPublisher)
-	if !ok {
-		return nil, status.Errorf(codes.Unimplemented, "Does not implement the BulkPublisher interface")
-	}
-
-	if !contribPubSub.FeatureBulkPublish.IsPresent(s.getInstance(ctx).Features()) {
-		return nil, status.Errorf(codes.Unimplemented, "BulkPublish method not enabled for this component")
-	}
-
-	entries := make([]contribPubSub.BulkMessageEntry, len(req.Entries))
-	for i, entry := range req.Entries {
-		entries[i] = contribPubSub.BulkMessageEntry{
-			EntryId:     entry.EntryId,
-			Event:       entry.Event,
-			ContentType: entry.ContentType,
-			Metadata:    entry.Metadata,
-		}
+	instance, err := s.getInstance(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	bulkPublishResponses, _ := bulkPublisherInstance.BulkPublish(ctx, &contribPubSub.BulkPublishRequest{
-		Entries:    entries,
+	contribReq := &contribPubSub.BulkPublishRequest{
+		Entries: internal.Map(req.Entries, func(entry *proto.BulkMessageEntry) contribPubSub.BulkMessageEntry {
+			return contribPubSub.BulkMessageEntry{
+				EntryId:     entry.EntryId,
+				Event:       entry.Event,
+				ContentType: entry.ContentType,
+				Metadata:    entry.Metadata,
+			}
+		}),
 		PubsubName: req.PubsubName,
 		Topic:      req.Topic,
 		Metadata:   req.Metadata,
-	})
+	}
 
-	responses := make([]*proto.BulkPublishResponseFailedEntry, len(bulkPublishResponses.FailedEntries))
-	for i, response := range bulkPublishResponses.FailedEntries {
-		responses[i] = &proto.BulkPublishResponseFailedEntry{
-			EntryId: response.EntryId,
-			Error:   response.Error.Error(),
-		}
+	var resp contribPubSub.BulkPublishResponse
+	if bulkPublisher, ok := instance.(BulkPublisher); ok && contribPubSub.FeatureBulkPublish.IsPresent(instance.Features()) {
+		resp, err = bulkPublisher.BulkPublish(ctx, contribReq)
+		resp = failedEntries(contribReq, resp, err)
+	} else {
+		resp = emulateBulkPublish(ctx, instance, contribReq, s.opts.bulkPublishParallelism)
 	}
 
 	return &proto.BulkPublishResponse{
-		FailedEntries: responses,
+		FailedEntries: internal.Map(resp.FailedEntries, func(entry contribPubSub.BulkPublishResponseFailedEntry) *proto.BulkPublishResponseFailedEntry {
+			return &proto.BulkPublishResponseFailedEntry{
+				EntryId: entry.EntryId,
+				Error:   entry.Error.Error(),
+			}
+		}),
 	}, nil
 }
 
-func (s *pubsub) Ping(context.Context, *proto.PingRequest) (*proto.PingResponse, error) {
-	return &proto.PingResponse{}, nil
+func (s *pubsub) Ping(ctx context.Context, _ *proto.PingRequest) (*proto.PingResponse, error) {
+	instance, err := s.getInstance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.PingResponse{}, sdkerrors.ToGRPC(internal.Ping(ctx, instance))
 }
 
-// Register the pubsub implementation for the component gRPC service.
-func Register(server *grpc.Server, getInstance func(context.Context) PubSub) {
+// Register the pubsub implementation for the component gRPC service, it returns the registry of its subscriptions.
+func Register(server *grpc.Server, getInstance func(context.Context) PubSub, opts ...Option) *Subscriptions {
+	return RegisterInstances(server, func(ctx context.Context) (PubSub, error) {
+		return getInstance(ctx), nil
+	}, opts...)
+}
+
+// RegisterInstances is like Register but gets the pubsub of each call from getInstance.
+func RegisterInstances(server *grpc.Server, getInstance func(context.Context) (PubSub, error), opts ...Option) *Subscriptions {
 	pubsub := &pubsub{
 		getInstance: getInstance,
+		opts:        newOptions(opts...),
+	}
+	pubsub.subscriptions = pubsub.opts.subscriptions
+	if pubsub.subscriptions == nil {
+		pubsub.subscriptions = NewSubscriptions()
 	}
 	proto.RegisterPubSubServer(server, pubsub)
+	return pubsub.subscriptions
 }
@@ -15,17 +15,24 @@ package pubsub
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	contribPubSub "github.com/dapr/components-contrib/pubsub"
 	proto "github.com/dapr/dapr/pkg/proto/components/v1"
 
+	sdkerrors "github.com/dapr-sandbox/components-go-sdk/errors"
+	"github.com/dapr-sandbox/components-go-sdk/internal"
+
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type fakeRecvResp struct {
@@ -120,6 +127,29 @@ func (f *fakePubSubImpl) Subscribe(_ context.Context, req contribPubSub.Subscrib
 	return f.subscribeErr
 }
 
+// fakeCtxPubSubImpl delivers a message to the handler with the context given to Subscribe,
+// as most components do, and reports the handler result.
+type fakeCtxPubSubImpl struct {
+	fakePubSubImpl
+	handlerResp chan error
+}
+
+func (f *fakeCtxPubSubImpl) Subscribe(ctx context.Context, _ contribPubSub.SubscribeRequest, handler contribPubSub.Handler) error {
+	go func() {
+		f.handlerResp <- handler(ctx, &contribPubSub.NewMessage{})
+	}()
+	return nil
+}
+
+type fakePingerPubSubImpl struct {
+	fakePubSubImpl
+	pingErr error
+}
+
+func (f *fakePingerPubSubImpl) Ping(context.Context) error {
+	return f.pingErr
+}
+
 func TestPubSubPullMessages(t *testing.T) {
 	t.Run("pullmessages should return an error when can't receive first message", func(t *testing.T) {
 		fakeErr := errors.New("fakeErr")
@@ -157,7 +187,8 @@ func TestPubSubPullMessages(t *testing.T) {
 			subscribeErr: fakeSubsErr,
 		}
 		ps := &pubsub{
-			getInstance: func(_ context.Context) PubSub { return impl },
+			subscriptions: NewSubscriptions(),
+			getInstance:   func(_ context.Context) (PubSub, error) { return impl, nil },
 		}
 		recvChan := make(chan *fakeRecvResp, 1)
 		recvChan <- &fakeRecvResp{
@@ -175,6 +206,42 @@ func TestPubSubPullMessages(t *testing.T) {
 		assert.Equal(t, int64(1), stream.recvCalled.Load())
 		assert.Equal(t, int64(1), impl.subscribeCalled.Load())
 	})
+	t.Run("pullmessages should return an invalid argument error when the ack timeout metadata is invalid", func(t *testing.T) {
+		impl := &fakePubSubImpl{}
+		ps := &pubsub{
+			subscriptions: NewSubscriptions(),
+			getInstance:   func(_ context.Context) (PubSub, error) { return impl, nil },
+		}
+		recvChan := make(chan *fakeRecvResp, 1)
+		recvChan <- &fakeRecvResp{
+			msg: &proto.PullMessagesRequest{
+				Topic: &proto.Topic{
+					Name:     "fake-topic",
+					Metadata: map[string]string{AckTimeoutMetadataKey: "soon"},
+				},
+			},
+		}
+		close(recvChan)
+		stream := &fakeStream{
+			recvChan: recvChan,
+		}
+		assert.Equal(t, codes.InvalidArgument, status.Code(ps.PullMessages(stream)))
+		assert.Equal(t, int64(0), impl.subscribeCalled.Load())
+	})
+	t.Run("subscription metadata should override the registration options", func(t *testing.T) {
+		opts := newOptions(WithAckTimeout(time.Minute), WithMaxConcurrentMessages(10))
+		sub, err := opts.subscriptionFor(nil)
+		require.NoError(t, err)
+		assert.Equal(t, opts, sub)
+
+		sub, err = opts.subscriptionFor(map[string]string{AckTimeoutMetadataKey: "5s", MaxConcurrentMessagesMetadataKey: "2"})
+		require.NoError(t, err)
+		assert.Equal(t, 5*time.Second, sub.ackTimeout)
+		assert.Equal(t, 2, sub.maxConcurrentMessages)
+
+		_, err = opts.subscriptionFor(map[string]string{MaxConcurrentMessagesMetadataKey: "many"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(sdkerrors.ToGRPC(err)))
+	})
 	t.Run("pullmessages should callback handler when new messages arrive", func(t *testing.T) {
 		const fakeTopic = "fake-topic"
 
@@ -196,7 +263,8 @@ func TestPubSubPullMessages(t *testing.T) {
 			subscribeCtx: context.Background(),
 		}
 		ps := &pubsub{
-			getInstance: func(_ context.Context) PubSub { return impl },
+			subscriptions: NewSubscriptions(),
+			getInstance:   func(_ context.Context) (PubSub, error) { return impl, nil },
 		}
 		recvChan := make(chan *fakeRecvResp, 3)
 		recvChan <- &fakeRecvResp{
@@ -233,6 +301,77 @@ func TestPubSubPullMessages(t *testing.T) {
 		assert.Equal(t, int64(1), impl.subscribeCalled.Load())
 		assert.Equal(t, int64(1), handleCount.Load())
 	})
+	t.Run("pullmessages should return when server is shutting down and no acks are pending", func(t *testing.T) {
+		const fakeTopic = "fake-topic"
+
+		impl := &fakePubSubImpl{}
+		ps := &pubsub{
+			subscriptions: NewSubscriptions(),
+			getInstance:   func(_ context.Context) (PubSub, error) { return impl, nil },
+		}
+		recvChan := make(chan *fakeRecvResp, 1)
+		recvChan <- &fakeRecvResp{
+			msg: &proto.PullMessagesRequest{
+				Topic: &proto.Topic{
+					Name: fakeTopic,
+				},
+			},
+		}
+		defer func() {
+			recvChan <- &fakeRecvResp{
+				err: io.EOF,
+			} // ends the ack loop
+		}()
+
+		shutdown := make(chan struct{})
+		close(shutdown)
+		stream := &fakeStream{
+			recvChan: recvChan,
+			ctx:      internal.WithShutdown(context.Background(), shutdown),
+		}
+		assert.Nil(t, ps.PullMessages(stream))
+		assert.Equal(t, int64(1), impl.subscribeCalled.Load())
+	})
+
+	t.Run("pullmessages should wait the in-flight messages to be acked when shutting down", func(t *testing.T) {
+		impl := &fakeCtxPubSubImpl{handlerResp: make(chan error, 1)}
+		ps := &pubsub{
+			subscriptions: NewSubscriptions(),
+			getInstance:   func(_ context.Context) (PubSub, error) { return impl, nil },
+		}
+		recvChan := make(chan *fakeRecvResp, 1)
+		recvChan <- &fakeRecvResp{
+			msg: &proto.PullMessagesRequest{
+				Topic: &proto.Topic{
+					Name: "fake-topic",
+				},
+			},
+		}
+		defer func() {
+			recvChan <- &fakeRecvResp{
+				err: io.EOF,
+			} // ends the ack loop
+		}()
+
+		shutdown := make(chan struct{})
+		stream := &fakeStream{
+			recvChan: recvChan,
+			ctx:      internal.WithShutdown(context.Background(), shutdown),
+			onSendCalled: func(msg *proto.PullMessagesResponse) {
+				close(shutdown)
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					recvChan <- &fakeRecvResp{
+						msg: &proto.PullMessagesRequest{
+							AckMessageId: msg.Id,
+						},
+					}
+				}()
+			},
+		}
+		assert.Nil(t, ps.PullMessages(stream))
+		assert.NoError(t, <-impl.handlerResp)
+	})
 }
 
 func TestPubSub(t *testing.T) {
@@ -242,7 +381,7 @@ func TestPubSub(t *testing.T) {
 			featuresResp: []contribPubSub.Feature{fakeFeature},
 		}
 		ps := &pubsub{
-			getInstance: func(_ context.Context) PubSub { return impl },
+			getInstance: func(_ context.Context) (PubSub, error) { return impl, nil },
 		}
 
 		resp, err := ps.Features(context.Background(), &proto.FeaturesRequest{})
@@ -254,10 +393,45 @@ func TestPubSub(t *testing.T) {
 	t.Run("publish should call impl publish", func(t *testing.T) {
 		impl := &fakePubSubImpl{}
 		ps := &pubsub{
-			getInstance: func(_ context.Context) PubSub { return impl },
+			getInstance: func(_ context.Context) (PubSub, error) { return impl, nil },
 		}
 		_, err := ps.Publish(context.Background(), &proto.PublishRequest{})
 		require.NoError(t, err)
 		assert.Equal(t, int64(1), impl.publishCalled.Load())
 	})
+
+	t.Run("ping should succeed when component does not implement pinger", func(t *testing.T) {
+		ps := &pubsub{
+			getInstance: func(_ context.Context) (PubSub, error) { return &fakePubSubImpl{}, nil },
+		}
+		_, err := ps.Ping(context.Background(), &proto.PingRequest{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("ping should delegate to the component when it implements pinger", func(t *testing.T) {
+		fakeErr := errors.New("fake-ping-err")
+		ps := &pubsub{
+			getInstance: func(_ context.Context) (PubSub, error) { return &fakePingerPubSubImpl{pingErr: fakeErr}, nil },
+		}
+		_, err := ps.Ping(context.Background(), &proto.PingRequest{})
+		assert.Equal(t, fakeErr, err)
+	})
+
+	t.Run("calls should fail when the instance can't be obtained", func(t *testing.T) {
+		fakeErr := errors.New("fake-instance-err")
+		ps := &pubsub{
+			getInstance: func(_ context.Context) (PubSub, error) { return nil, fakeErr },
+		}
+		_, err := ps.Publish(context.Background(), &proto.PublishRequest{})
+		assert.Equal(t, fakeErr, err)
+	})
+
+	t.Run("publish should translate typed errors into gRPC status errors", func(t *testing.T) {
+		impl := &fakePubSubImpl{publishErr: fmt.Errorf("fake-publish-err: %w", sdkerrors.Unavailable("broker is down"))}
+		ps := &pubsub{
+			getInstance: func(_ context.Context) (PubSub, error) { return impl, nil },
+		}
+		_, err := ps.Publish(context.Background(), &proto.PublishRequest{})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
 }
@@ -0,0 +1,74 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dapr
+
+import (
+	"context"
+
+	"github.com/dapr-sandbox/components-go-sdk/internal"
+	"github.com/dapr/kit/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// panicRecovery converts the components panics into internal errors,
+// so a failing instance does not take down the process and every other component it hosts.
+type panicRecovery struct {
+	log logger.Logger
+	// recreate evicts the instance that panicked and marks its service as not serving.
+	recreate     bool
+	healthServer *health.Server
+	instances    *instancesRegistry
+}
+
+// recovered handles the value recovered from a panic that happened while serving the given method.
+func (p *panicRecovery) recovered(ctx context.Context, fullMethod string, r any) error {
+	err := internal.PanicError(ctx, p.log, r)
+	if !p.recreate {
+		return err
+	}
+
+	service, _ := splitMethod(fullMethod)
+	if manager, ok := p.instances.managers[service]; ok {
+		manager.evict(instanceIDFromIncomingContext(ctx))
+	}
+	setServingStatus(p.healthServer, service, healthpb.HealthCheckResponse_NOT_SERVING)
+	return err
+}
+
+// unaryInterceptor recovers from panics on unary calls.
+func (p *panicRecovery) unaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = p.recovered(ctx, info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// streamInterceptor recovers from panics on streaming calls.
+func (p *panicRecovery) streamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = p.recovered(ss.Context(), info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dapr
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	contribState "github.com/dapr/components-contrib/state"
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+
+	"github.com/dapr-sandbox/components-go-sdk/state/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+type fakePanicStateStore struct {
+	state.Store
+}
+
+func (f *fakePanicStateStore) Init(context.Context, contribState.Metadata) error {
+	return nil
+}
+
+func (f *fakePanicStateStore) Set(context.Context, *contribState.SetRequest) error {
+	panic("fake-panic")
+}
+
+// fakeInitPanicStateStore panics on Init when panics is set.
+type fakeInitPanicStateStore struct {
+	state.Store
+	panics bool
+}
+
+func (f *fakeInitPanicStateStore) Init(context.Context, contribState.Metadata) error {
+	if f.panics {
+		panic("fake-init-panic")
+	}
+	return nil
+}
+
+// serveFakeComponent serves the given options on a new server until the test ends and returns a connection to its socket.
+func serveFakeComponent(t *testing.T, name string, opts ...option) *grpc.ClientConn {
+	t.Helper()
+	socketFolder := socketTempDir(t)
+	server := NewServer(WithSocketFolder(socketFolder))
+	server.Register(name, opts...)
+	go server.Serve(context.Background()) //nolint:errcheck
+	t.Cleanup(server.Stop)
+
+	socket := filepath.Join(socketFolder, name+".sock")
+	waitForSocket(t, socket)
+	return dialSocket(t, socket)
+}
+
+func TestRecovery(t *testing.T) {
+	t.Run("panics should be returned as internal errors without affecting other calls", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		conn := serveFakeComponent(t, "fake-recovery", WithStateStore(func() state.Store { return &fakePanicStateStore{} }))
+		client := proto.NewStateStoreClient(conn)
+
+		_, err := client.Set(ctx, &proto.SetRequest{Key: "key"})
+		assert.Equal(t, codes.Internal, status.Code(err))
+
+		_, err = client.Init(ctx, &proto.InitRequest{Metadata: &proto.MetadataRequest{}})
+		assert.NoError(t, err)
+	})
+
+	t.Run("recreate on panic should evict the instance and mark the service as not serving", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		var created atomic.Int64
+		conn := serveFakeComponent(t, "fake-recreate", WithStateStore(func() state.Store {
+			created.Add(1)
+			return &fakePanicStateStore{}
+		}), WithRecreateOnPanic())
+		client := proto.NewStateStoreClient(conn)
+		healthClient := healthpb.NewHealthClient(conn)
+
+		_, err := client.Init(ctx, &proto.InitRequest{Metadata: &proto.MetadataRequest{}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), created.Load())
+
+		_, err = client.Set(ctx, &proto.SetRequest{Key: "key"})
+		assert.Equal(t, codes.Internal, status.Code(err))
+
+		resp, err := healthClient.Check(ctx, &healthpb.HealthCheckRequest{Service: proto.StateStore_ServiceDesc.ServiceName})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+		_, err = client.Ping(ctx, &proto.PingRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), created.Load())
+
+		resp, err = healthClient.Check(ctx, &healthpb.HealthCheckRequest{Service: proto.StateStore_ServiceDesc.ServiceName})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	})
+	t.Run("init panics should mark the service as not serving and discard the instance", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		var created atomic.Int64
+		conn := serveFakeComponent(t, "fake-init-panic", WithStateStore(func() state.Store {
+			return &fakeInitPanicStateStore{panics: created.Add(1) == 1}
+		}))
+		client := proto.NewStateStoreClient(conn)
+		healthClient := healthpb.NewHealthClient(conn)
+
+		_, err := client.Init(ctx, &proto.InitRequest{Metadata: &proto.MetadataRequest{}})
+		assert.Equal(t, codes.Internal, status.Code(err))
+
+		resp, err := healthClient.Check(ctx, &healthpb.HealthCheckRequest{Service: proto.StateStore_ServiceDesc.ServiceName})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+		_, err = client.Init(ctx, &proto.InitRequest{Metadata: &proto.MetadataRequest{}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), created.Load())
+
+		resp, err = healthClient.Check(ctx, &healthpb.HealthCheckRequest{Service: proto.StateStore_ServiceDesc.ServiceName})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	})
+}
@@ -14,13 +14,20 @@ limitations under the License.
 package dapr
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"github.com/dapr-sandbox/components-go-sdk/bindings/v1"
 	"github.com/dapr-sandbox/components-go-sdk/pubsub/v1"
 	"github.com/dapr-sandbox/components-go-sdk/state/v1"
 	"google.golang.org/grpc"
 
+	contribBindings "github.com/dapr/components-contrib/bindings"
+	contribMetadata "github.com/dapr/components-contrib/metadata"
+	contribPubSub "github.com/dapr/components-contrib/pubsub"
+	contribState "github.com/dapr/components-contrib/state"
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
 	"github.com/dapr/kit/logger"
 )
 
@@ -30,49 +37,167 @@ var (
 )
 
 type componentsOpts struct {
-	useGrpcServer []func(*grpc.Server)
+	useGrpcServer     []func(*grpc.Server, *instancesRegistry)
+	listener          Listener
+	grpcServerOptions []grpc.ServerOption
+	instancePolicy    instancePolicy
+	recreateOnPanic   bool
 }
 
-var factories = make(map[string]*componentsOpts)
-
 type option = func(*componentsOpts)
 
+// ComponentType is the type of a component served by the SDK.
+type ComponentType string
+
+const (
+	ComponentTypeStateStore    ComponentType = "state"
+	ComponentTypePubSub        ComponentType = "pubsub"
+	ComponentTypeInputBinding  ComponentType = "bindings.input"
+	ComponentTypeOutputBinding ComponentType = "bindings.output"
+)
+
+// InstanceInfo identifies the component instance being created by a factory.
+// instances are created on the first call that uses them, so a factory error is sent back to daprd as the result of that call:
+// as is when it is a gRPC status error or an error of the errors package, or as an internal error otherwise.
+type InstanceInfo struct {
+	// InstanceID is the ID of the component instance sent by daprd using the `x-component-instance` metadata header,
+	// it is empty when daprd does not send it.
+	InstanceID string
+	// Name is the name the component was registered with, which is also the socket name.
+	Name string
+	// Type is the type of the component being created.
+	Type ComponentType
+}
+
+// infallible adapts a factory that can't fail to the factory signature that receives the instance info.
+func infallible[TComponent any](factory func() TComponent) func(context.Context, InstanceInfo) (TComponent, error) {
+	return func(context.Context, InstanceInfo) (TComponent, error) {
+		return factory(), nil
+	}
+}
+
 // WithPubSub adds pubsub factory for the component.
-func WithPubSub(factory func() pubsub.PubSub) option {
+func WithPubSub(factory func() pubsub.PubSub, opts ...pubsub.Option) option {
+	return WithPubSubFactory(infallible(factory), opts...)
+}
+
+// WithPubSubFactory adds a pubsub factory that receives the instance being created and can fail.
+func WithPubSubFactory(factory func(context.Context, InstanceInfo) (pubsub.PubSub, error), opts ...pubsub.Option) option {
 	return func(cf *componentsOpts) {
-		cf.useGrpcServer = append(cf.useGrpcServer, func(s *grpc.Server) {
-			pubsub.Register(s, mux(factory))
+		cf.useGrpcServer = append(cf.useGrpcServer, func(s *grpc.Server, r *instancesRegistry) {
+			instances := newInstances(factory, func(ctx context.Context, ps pubsub.PubSub, properties map[string]string) error {
+				return ps.Init(ctx, contribPubSub.Metadata{Base: contribMetadata.Base{Properties: properties}})
+			}, r.policy, r.instanceInfo(ComponentTypePubSub), r.log)
+			r.add(proto.PubSub_ServiceDesc.ServiceName, instances)
+			pubsub.RegisterInstances(s, instances.get, opts...)
 		})
 	}
 }
 
 // WithStateStore adds statestore factory for the component.
-func WithStateStore(factory func() state.Store) option {
+func WithStateStore(factory func() state.Store, opts ...state.Option) option {
+	return WithStateStoreFactory(infallible(factory), opts...)
+}
+
+// WithStateStoreFactory adds a statestore factory that receives the instance being created and can fail.
+func WithStateStoreFactory(factory func(context.Context, InstanceInfo) (state.Store, error), opts ...state.Option) option {
 	return func(cf *componentsOpts) {
-		cf.useGrpcServer = append(cf.useGrpcServer, func(s *grpc.Server) {
-			state.Register(s, mux(factory))
+		cf.useGrpcServer = append(cf.useGrpcServer, func(s *grpc.Server, r *instancesRegistry) {
+			instances := newInstances(factory, func(ctx context.Context, store state.Store, properties map[string]string) error {
+				return store.Init(ctx, contribState.Metadata{Base: contribMetadata.Base{Properties: properties}})
+			}, r.policy, r.instanceInfo(ComponentTypeStateStore), r.log)
+			r.add(proto.StateStore_ServiceDesc.ServiceName, instances)
+			state.RegisterInstances(s, instances.get, opts...)
 		})
 	}
 }
 
 // WithInputBinding adds inputbinding factory for the component.
 func WithInputBinding(factory func() bindings.InputBinding) option {
+	return WithInputBindingFactory(infallible(factory))
+}
+
+// WithInputBindingFactory adds an inputbinding factory that receives the instance being created and can fail.
+func WithInputBindingFactory(factory func(context.Context, InstanceInfo) (bindings.InputBinding, error)) option {
 	return func(cf *componentsOpts) {
-		cf.useGrpcServer = append(cf.useGrpcServer, func(s *grpc.Server) {
-			bindings.RegisterInput(s, mux(factory))
+		cf.useGrpcServer = append(cf.useGrpcServer, func(s *grpc.Server, r *instancesRegistry) {
+			instances := newInstances(factory, func(ctx context.Context, binding bindings.InputBinding, properties map[string]string) error {
+				return binding.Init(ctx, contribBindings.Metadata{Base: contribMetadata.Base{Properties: properties}})
+			}, r.policy, r.instanceInfo(ComponentTypeInputBinding), r.log)
+			r.add(proto.InputBinding_ServiceDesc.ServiceName, instances)
+			bindings.RegisterInputInstances(s, instances.get)
 		})
 	}
 }
 
 // WithOutputBinding adds outputbinding factory for the component.
 func WithOutputBinding(factory func() bindings.OutputBinding) option {
+	return WithOutputBindingFactory(infallible(factory))
+}
+
+// WithOutputBindingFactory adds an outputbinding factory that receives the instance being created and can fail.
+func WithOutputBindingFactory(factory func(context.Context, InstanceInfo) (bindings.OutputBinding, error)) option {
 	return func(cf *componentsOpts) {
-		cf.useGrpcServer = append(cf.useGrpcServer, func(s *grpc.Server) {
-			bindings.RegisterOutput(s, mux(factory))
+		cf.useGrpcServer = append(cf.useGrpcServer, func(s *grpc.Server, r *instancesRegistry) {
+			instances := newInstances(factory, func(ctx context.Context, binding bindings.OutputBinding, properties map[string]string) error {
+				return binding.Init(ctx, contribBindings.Metadata{Base: contribMetadata.Base{Properties: properties}})
+			}, r.policy, r.instanceInfo(ComponentTypeOutputBinding), r.log)
+			r.add(proto.OutputBinding_ServiceDesc.ServiceName, instances)
+			bindings.RegisterOutputInstances(s, instances.get)
 		})
 	}
 }
 
+// WithInstanceIdleTTL sets how long a component instance can stay unused before being closed and evicted.
+// evicted instances are created and initialized again with the same metadata on the next call.
+func WithInstanceIdleTTL(ttl time.Duration) option {
+	return func(cf *componentsOpts) {
+		cf.instancePolicy.idleTTL = ttl
+	}
+}
+
+// WithMaxInstances sets the maximum number of cached instances per component type,
+// the least recently used instance is closed and evicted when the limit is reached.
+func WithMaxInstances(max int) option {
+	return func(cf *componentsOpts) {
+		cf.instancePolicy.maxInstances = max
+	}
+}
+
+// WithRecreateOnPanic closes and evicts a component instance that panicked and marks its services as not serving.
+// the instance is created and initialized again with the same metadata on the next call.
+// panics are always converted into internal errors, regardless of this option.
+func WithRecreateOnPanic() option {
+	return func(cf *componentsOpts) {
+		cf.recreateOnPanic = true
+	}
+}
+
+// WithListener sets the listener used to serve the component, a unix socket is used when none is specified.
+func WithListener(listener Listener) option {
+	return func(cf *componentsOpts) {
+		cf.listener = listener
+	}
+}
+
+// WithGRPCServerOptions adds options to the gRPC server used to serve the component.
+// they are applied after the server default options, see WithDefaultGRPCServerOptions.
+func WithGRPCServerOptions(opts ...grpc.ServerOption) option {
+	return func(cf *componentsOpts) {
+		cf.grpcServerOptions = append(cf.grpcServerOptions, opts...)
+	}
+}
+
+// WithUnaryInterceptors chains the given unary interceptors to the gRPC server used to serve the component.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) option {
+	return WithGRPCServerOptions(grpc.ChainUnaryInterceptor(interceptors...))
+}
+
+// WithStreamInterceptors chains the given stream interceptors to the gRPC server used to serve the component.
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) option {
+	return WithGRPCServerOptions(grpc.ChainStreamInterceptor(interceptors...))
+}
+
 // validate check options are valid.
 // if none component was specified so it will return an error.
 func (c *componentsOpts) validate() error {
@@ -82,14 +207,22 @@ func (c *componentsOpts) validate() error {
 	return nil
 }
 
-// apply applies the options to the given grpcServer.
-func (c *componentsOpts) apply(s *grpc.Server) error {
+// getListener returns the component listener or the default unix socket listener when none was specified.
+func (c *componentsOpts) getListener() Listener {
+	if c.listener == nil {
+		return UnixSocketListener()
+	}
+	return c.listener
+}
+
+// apply applies the options to the given grpcServer, registering the instances managers on the given registry.
+func (c *componentsOpts) apply(s *grpc.Server, r *instancesRegistry) error {
 	if err := c.validate(); err != nil {
 		return err
 	}
 
 	for _, useGrpcServer := range c.useGrpcServer {
-		useGrpcServer(s)
+		useGrpcServer(s, r)
 	}
 
 	return nil
@@ -101,16 +234,21 @@ func (c *componentsOpts) merge(other *componentsOpts) *componentsOpts {
 		return c
 	}
 	c.useGrpcServer = append(c.useGrpcServer, other.useGrpcServer...)
+	c.grpcServerOptions = append(append([]grpc.ServerOption{}, other.grpcServerOptions...), c.grpcServerOptions...)
+	if c.listener == nil {
+		c.listener = other.listener
+	}
+	if c.instancePolicy.idleTTL == 0 {
+		c.instancePolicy.idleTTL = other.instancePolicy.idleTTL
+	}
+	if c.instancePolicy.maxInstances == 0 {
+		c.instancePolicy.maxInstances = other.instancePolicy.maxInstances
+	}
+	c.recreateOnPanic = c.recreateOnPanic || other.recreateOnPanic
 	return c
 }
 
-// Register a component with the given name.
+// Register a component with the given name on the default server.
 func Register(name string, opts ...option) {
-	cmpFactories := &componentsOpts{}
-
-	for _, opt := range opts {
-		opt(cmpFactories)
-	}
-
-	factories[name] = cmpFactories.merge(factories[name])
+	defaultServer.Register(name, opts...)
 }
@@ -14,12 +14,17 @@ limitations under the License.
 package dapr
 
 import (
+	"context"
 	"testing"
 
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+	"github.com/dapr/kit/logger"
+
 	"github.com/dapr-sandbox/components-go-sdk/bindings/v1"
 	"github.com/dapr-sandbox/components-go-sdk/pubsub/v1"
 	"github.com/dapr-sandbox/components-go-sdk/state/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
 )
 
@@ -45,8 +50,8 @@ func TestOptions(t *testing.T) {
 
 	t.Run("validate should not return an error when at least one component is specified", func(t *testing.T) {
 		opts := &componentsOpts{
-			useGrpcServer: []func(*grpc.Server){
-				func(*grpc.Server) {},
+			useGrpcServer: []func(*grpc.Server, *instancesRegistry){
+				func(*grpc.Server, *instancesRegistry) {},
 			},
 		}
 		assert.Nil(t, opts.validate())
@@ -54,7 +59,7 @@ func TestOptions(t *testing.T) {
 
 	t.Run("apply should return an error if validate returns an error", func(t *testing.T) {
 		opts := &componentsOpts{}
-		assert.NotNil(t, opts.apply(&grpc.Server{}))
+		assert.NotNil(t, opts.apply(&grpc.Server{}, newInstancesRegistry("", instancePolicy{}, svcLogger)))
 	})
 
 	t.Run("withPubSub should add a new useGrpcServer callback", func(t *testing.T) {
@@ -86,4 +91,38 @@ func TestOptions(t *testing.T) {
 		opt(opts)
 		assert.Len(t, opts.useGrpcServer, 1)
 	})
+
+	t.Run("registered instances should log through the registry logger", func(t *testing.T) {
+		log := logger.NewLogger("fake-server")
+		registry := newInstancesRegistry("", instancePolicy{}, log)
+		opts := &componentsOpts{}
+		WithStateStore(func() state.Store { return &fakeStateStore{} })(opts)
+		assert.NoError(t, opts.apply(grpc.NewServer(), registry))
+
+		instances, ok := registry.managers[proto.StateStore_ServiceDesc.ServiceName].(*instances[state.Store])
+		require.True(t, ok)
+		assert.Equal(t, log, instances.log)
+	})
+
+	t.Run("withGRPCServerOptions should add the given server options", func(t *testing.T) {
+		opts := &componentsOpts{}
+		WithGRPCServerOptions(grpc.MaxRecvMsgSize(1), grpc.MaxSendMsgSize(1))(opts)
+		WithUnaryInterceptors(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			return handler(ctx, req)
+		})(opts)
+		assert.Len(t, opts.grpcServerOptions, 3)
+	})
+
+	t.Run("merge should keep previous server options and listener", func(t *testing.T) {
+		previous := &componentsOpts{
+			grpcServerOptions: []grpc.ServerOption{grpc.MaxRecvMsgSize(1)},
+			listener:          TCPListener(":0"),
+		}
+		opts := &componentsOpts{
+			grpcServerOptions: []grpc.ServerOption{grpc.MaxSendMsgSize(1)},
+		}
+		merged := opts.merge(previous)
+		assert.Len(t, merged.grpcServerOptions, 2)
+		assert.Equal(t, previous.listener, merged.listener)
+	})
 }
@@ -0,0 +1,349 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dapr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dapr-sandbox/components-go-sdk/internal"
+
+	"github.com/dapr/kit/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/reflection"
+)
+
+// ErrServerAlreadyServing is returned when Serve is called on a server that is already serving.
+var ErrServerAlreadyServing = errors.New("server is already serving")
+
+// ServerOption configures how the components server runs.
+type ServerOption func(*serverOpts)
+
+type serverOpts struct {
+	drainTimeout      time.Duration
+	failFast          bool
+	socketFolder      string
+	logger            logger.Logger
+	grpcServerOptions []grpc.ServerOption
+}
+
+// newServerOpts builds the server options with the defaults values.
+func newServerOpts(opts ...ServerOption) *serverOpts {
+	sopts := &serverOpts{
+		drainTimeout: defaultDrainTimeout,
+		failFast:     true,
+		logger:       svcLogger,
+	}
+	for _, opt := range opts {
+		opt(sopts)
+	}
+	return sopts
+}
+
+// WithDrainTimeout sets how long the server waits for in-flight calls and streams to finish when shutting down.
+// after the timeout all remaining calls and streams are forcibly closed.
+func WithDrainTimeout(timeout time.Duration) ServerOption {
+	return func(so *serverOpts) {
+		so.drainTimeout = timeout
+	}
+}
+
+// WithFailFast sets whether the server should stop all components when one of them fails.
+// when disabled the healthy components keep serving and the failures are returned once all of them stop.
+func WithFailFast(failFast bool) ServerOption {
+	return func(so *serverOpts) {
+		so.failFast = failFast
+	}
+}
+
+// WithSocketFolder sets the folder where the components sockets are created.
+// when not set the folder is read from the DAPR_COMPONENT_SOCKETS_FOLDER environment variable.
+func WithSocketFolder(folder string) ServerOption {
+	return func(so *serverOpts) {
+		so.socketFolder = folder
+	}
+}
+
+// WithLogger sets the logger used by the server.
+func WithLogger(logger logger.Logger) ServerOption {
+	return func(so *serverOpts) {
+		so.logger = logger
+	}
+}
+
+// WithDefaultGRPCServerOptions adds options to the gRPC servers of all registered components.
+// component specific options can be added using WithGRPCServerOptions when registering the component.
+func WithDefaultGRPCServerOptions(opts ...grpc.ServerOption) ServerOption {
+	return func(so *serverOpts) {
+		so.grpcServerOptions = append(so.grpcServerOptions, opts...)
+	}
+}
+
+// ComponentError is the error returned when a registered component could not be served.
+type ComponentError struct {
+	// Name is the name used to register the component.
+	Name string
+	// Address is the socket or the network address the component was bound to.
+	Address string
+	// Err is the error that caused the component to fail.
+	Err error
+}
+
+func (e *ComponentError) Error() string {
+	return fmt.Sprintf("component %s failed at %s: %v", e.Name, e.Address, e.Err)
+}
+
+func (e *ComponentError) Unwrap() error {
+	return e.Err
+}
+
+// Server hosts a set of components, each one of them bound to its own socket.
+type Server struct {
+	opts      *serverOpts
+	mu        sync.Mutex
+	factories map[string]*componentsOpts
+	stop      context.CancelFunc
+}
+
+// NewServer creates a new components server with its own components registry.
+func NewServer(opts ...ServerOption) *Server {
+	return &Server{
+		opts:      newServerOpts(opts...),
+		factories: make(map[string]*componentsOpts),
+	}
+}
+
+// Register a component with the given name.
+// Components registered while the server is serving are only served on the next Serve call.
+func (s *Server) Register(name string, opts ...option) {
+	cmpFactories := &componentsOpts{}
+
+	for _, opt := range opts {
+		opt(cmpFactories)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.factories[name] = cmpFactories.merge(s.factories[name])
+}
+
+// socketFolder returns the folder where the sockets should be created.
+func (s *Server) socketFolder() string {
+	if s.opts.socketFolder != "" {
+		return s.opts.socketFolder
+	}
+	socketFolder, ok := os.LookupEnv(unixSocketFolderPathEnvVar)
+	if !ok {
+		socketFolder, ok = os.LookupEnv(fallbackUnixSocketFolderPathEnvVar)
+		if !ok {
+			socketFolder = defaultSocketFolder
+		}
+	}
+	return socketFolder
+}
+
+// Serve starts serving the registered components and blocks until the given context is done or Stop is called.
+// When stopping it stops accepting new calls, stops handing new messages to the streams
+// and waits for in-flight calls and pending acks to settle before returning.
+// The returned error joins a *ComponentError for each component that could not be served.
+func (s *Server) Serve(ctx context.Context) error {
+	s.mu.Lock()
+	if s.stop != nil {
+		s.mu.Unlock()
+		return ErrServerAlreadyServing
+	}
+	if len(s.factories) == 0 {
+		s.mu.Unlock()
+		return ErrNoComponentsRegistered
+	}
+	factories := make(map[string]*componentsOpts, len(s.factories))
+	for name, opts := range s.factories {
+		factories[name] = opts
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s.stop = cancel
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.stop = nil
+		s.mu.Unlock()
+		cancel()
+	}()
+
+	socketFolder := s.socketFolder()
+
+	var (
+		wg    sync.WaitGroup
+		errMu sync.Mutex
+		errs  []error
+	)
+
+	for component, opts := range factories {
+		address := opts.getListener().Address(component, socketFolder)
+		wg.Add(1)
+		go func(name string, opts *componentsOpts) {
+			defer wg.Done()
+			err := s.runComponent(ctx, name, address, opts)
+			if err == nil {
+				return
+			}
+
+			errMu.Lock()
+			errs = append(errs, &ComponentError{Name: name, Address: address, Err: err})
+			errMu.Unlock()
+
+			if s.opts.failFast {
+				s.opts.logger.Errorf("aborting due to an error on component %s: %v", name, err)
+				cancel()
+				return
+			}
+			s.opts.logger.Errorf("component %s stopped due to an error: %v", name, err)
+		}(component, opts)
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Stop gracefully stops the server, it is a no-op when the server is not serving.
+func (s *Server) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		s.stop()
+	}
+}
+
+// shutdownStreamInterceptor propagates the shutdown signal to the streams contexts.
+func shutdownStreamInterceptor(shutdown <-chan struct{}) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{
+			ServerStream: ss,
+			ctx:          internal.WithShutdown(ss.Context(), shutdown),
+		})
+	}
+}
+
+// serverStream overrides the grpc.ServerStream context.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// gracefulStop stops the server waiting for in-flight calls and streams up to the given timeout.
+func (s *Server) gracefulStop(server *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	timer := time.NewTimer(s.opts.drainTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-stopped:
+	case <-timer.C:
+		s.opts.logger.Warnf("in-flight calls did not finish within %s, forcing shutdown", s.opts.drainTimeout)
+		server.Stop()
+		<-stopped
+	}
+}
+
+func (s *Server) runComponent(ctx context.Context, name, address string, opts *componentsOpts) error {
+	s.opts.logger.Infof("using address defined at '%s'", address)
+
+	listener := opts.getListener()
+	if l, ok := listener.(loggingListener); ok {
+		listener = l.withLogger(s.opts.logger)
+	}
+	lis, err := listener.Listen(address)
+	if err != nil {
+		return err
+	}
+
+	defer lis.Close()
+
+	shutdown := make(chan struct{})
+	healthServer := health.NewServer()
+	instances := newInstancesRegistry(name, opts.instancePolicy, s.opts.logger)
+	recovery := &panicRecovery{
+		log:          s.opts.logger,
+		recreate:     opts.recreateOnPanic,
+		healthServer: healthServer,
+		instances:    instances,
+	}
+	// recovery is the innermost interceptor, so the health and instances interceptors see panics as errors.
+	grpcServerOptions := []grpc.ServerOption{
+		grpc.ChainStreamInterceptor(
+			instanceStreamInterceptor(name),
+			shutdownStreamInterceptor(shutdown),
+			instancesStreamInterceptor(instances),
+			recovery.streamInterceptor(),
+		),
+		grpc.ChainUnaryInterceptor(
+			instanceUnaryInterceptor(name),
+			healthUnaryInterceptor(healthServer),
+			instancesUnaryInterceptor(instances),
+			recovery.unaryInterceptor(),
+		),
+	}
+	grpcServerOptions = append(grpcServerOptions, s.opts.grpcServerOptions...)
+	grpcServerOptions = append(grpcServerOptions, opts.grpcServerOptions...)
+	server := grpc.NewServer(grpcServerOptions...)
+
+	if err = opts.apply(server, instances); err != nil {
+		return err
+	}
+	defer func() {
+		if err := instances.closeAll(); err != nil {
+			s.opts.logger.Warnf("error when closing component instances: %v", err)
+		}
+	}()
+
+	evictCtx, stopEviction := context.WithCancel(ctx)
+	defer stopEviction()
+	go instances.evictIdleEvery(evictCtx)
+
+	registerHealth(server, healthServer)
+	reflection.Register(server)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(lis)
+	}()
+
+	select {
+	case err = <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// stop handing new messages to the streams and wait for them to settle pending acks.
+	healthServer.Shutdown()
+	close(shutdown)
+	s.gracefulStop(server)
+	return <-serveErr
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dapr
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dapr-sandbox/components-go-sdk/state/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+)
+
+// socketTempDir creates a temporary folder with a short path, unix sockets paths are limited to 108 characters.
+func socketTempDir(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "dapr")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+// waitForSocket waits until the given socket is created.
+func waitForSocket(t *testing.T, socket string) {
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(socket)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+// dialSocket creates a gRPC client connection to the given unix socket.
+func dialSocket(t *testing.T, socket string) *grpc.ClientConn {
+	conn, err := grpc.Dial("unix://"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return conn
+}
+
+func TestServer(t *testing.T) {
+	t.Run("serve should return an error when no component was registered", func(t *testing.T) {
+		t.Parallel()
+		server := NewServer(WithSocketFolder(socketTempDir(t)))
+		assert.Equal(t, ErrNoComponentsRegistered, server.Serve(context.Background()))
+	})
+
+	t.Run("servers should have isolated registries", func(t *testing.T) {
+		t.Parallel()
+		serverA, serverB := NewServer(), NewServer()
+		serverA.Register("fake-component", WithStateStore(func() state.Store { return &fakeStateStore{} }))
+		assert.Len(t, serverA.factories, 1)
+		assert.Empty(t, serverB.factories)
+	})
+
+	t.Run("stop should gracefully stop a serving server", func(t *testing.T) {
+		t.Parallel()
+		const fakeComponent = "fake-stop"
+		socketFolder := socketTempDir(t)
+		server := NewServer(WithSocketFolder(socketFolder), WithDrainTimeout(time.Second))
+		server.Register(fakeComponent, WithStateStore(func() state.Store { return &fakeStateStore{} }))
+
+		serveErr := make(chan error, 1)
+		go func() {
+			serveErr <- server.Serve(context.Background())
+		}()
+		waitForSocket(t, filepath.Join(socketFolder, fakeComponent+".sock"))
+		assert.Equal(t, ErrServerAlreadyServing, server.Serve(context.Background()))
+
+		server.Stop()
+		select {
+		case err := <-serveErr:
+			assert.Nil(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("serve should return after stop is called")
+		}
+	})
+
+	t.Run("serve should return the component error when a component fails", func(t *testing.T) {
+		t.Parallel()
+		const fakeComponent = "fake-failing-component"
+		server := NewServer(WithSocketFolder(filepath.Join(t.TempDir(), "not-found")))
+		server.Register(fakeComponent, WithStateStore(func() state.Store { return &fakeStateStore{} }))
+
+		err := server.Serve(context.Background())
+		require.NotNil(t, err)
+		var componentErr *ComponentError
+		require.ErrorAs(t, err, &componentErr)
+		assert.Equal(t, fakeComponent, componentErr.Name)
+	})
+
+	t.Run("serve should keep healthy components serving when fail fast is disabled", func(t *testing.T) {
+		t.Parallel()
+		const (
+			fakeComponent        = "fake-healthy-component"
+			fakeFailingComponent = "fake-invalid-component"
+		)
+		socketFolder := socketTempDir(t)
+		server := NewServer(WithSocketFolder(socketFolder), WithFailFast(false))
+		server.Register(fakeComponent, WithStateStore(func() state.Store { return &fakeStateStore{} }))
+		server.Register(fakeFailingComponent) // no component services, so it fails when applying options.
+
+		ctx, cancel := context.WithCancel(context.Background())
+		serveErr := make(chan error, 1)
+		go func() {
+			serveErr <- server.Serve(ctx)
+		}()
+
+		socket := filepath.Join(socketFolder, fakeComponent+".sock")
+		waitForSocket(t, socket)
+
+		// the healthy component should still be serving.
+		time.Sleep(50 * time.Millisecond)
+		_, err := os.Stat(socket)
+		require.NoError(t, err)
+
+		cancel()
+		err = <-serveErr
+		var componentErr *ComponentError
+		require.ErrorAs(t, err, &componentErr)
+		assert.Equal(t, fakeFailingComponent, componentErr.Name)
+		assert.ErrorIs(t, err, ErrNoneComponentsFound)
+	})
+
+	t.Run("serve should apply default and component grpc server options", func(t *testing.T) {
+		t.Parallel()
+		const fakeComponent = "fake-interceptors"
+		socketFolder := socketTempDir(t)
+		var calls []string
+		var callsMu sync.Mutex
+		interceptor := func(name string) grpc.UnaryServerInterceptor {
+			return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				callsMu.Lock()
+				calls = append(calls, name)
+				callsMu.Unlock()
+				return handler(ctx, req)
+			}
+		}
+		server := NewServer(
+			WithSocketFolder(socketFolder),
+			WithDefaultGRPCServerOptions(grpc.ChainUnaryInterceptor(interceptor("default"))),
+		)
+		server.Register(fakeComponent,
+			WithStateStore(func() state.Store { return &fakeStateStore{} }),
+			WithUnaryInterceptors(interceptor("component")),
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go server.Serve(ctx) //nolint:errcheck
+
+		socket := filepath.Join(socketFolder, fakeComponent+".sock")
+		waitForSocket(t, socket)
+
+		_, err := proto.NewStateStoreClient(dialSocket(t, socket)).Ping(ctx, &proto.PingRequest{})
+		require.NoError(t, err)
+
+		callsMu.Lock()
+		defer callsMu.Unlock()
+		assert.Equal(t, []string{"default", "component"}, calls)
+	})
+}
@@ -14,16 +14,12 @@ limitations under the License.
 package dapr
 
 import (
+	"context"
 	"errors"
-	"net"
 	"os"
 	"os/signal"
-	"path/filepath"
-	"sync"
 	"syscall"
-
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/reflection"
+	"time"
 )
 
 // ErrNoComponentsRegistered is returned when none components was registered.
@@ -33,98 +29,40 @@ const (
 	fallbackUnixSocketFolderPathEnvVar = "DAPR_COMPONENT_SOCKET_FOLDER"  // keep backwards compatible
 	unixSocketFolderPathEnvVar         = "DAPR_COMPONENT_SOCKETS_FOLDER" // plural version should be used.
 	defaultSocketFolder                = "/tmp/dapr-components-sockets"
+	defaultDrainTimeout                = 30 * time.Second
 )
 
-// makeAbortChan Generates a chan bool that automatically gets closed when the process
-// receives a SIGINT or SIGTERM.
-func makeAbortChan(done chan struct{}) chan struct{} {
-	abortChan := make(chan struct{})
-	sigChan := make(chan os.Signal, 1)
-
-	go func() {
-		select {
-		case <-sigChan:
-			close(abortChan)
-		case <-done:
-			close(abortChan)
-		}
-	}()
+// defaultServer is the server used by the package level Register and Run functions.
+var defaultServer = NewServer()
 
-	signal.Notify(sigChan,
+// Run starts the component server.
+// The server stops gracefully when the process receives a SIGINT or SIGTERM.
+func Run() error {
+	ctx, cancel := signal.NotifyContext(context.Background(),
 		os.Interrupt,
 		syscall.SIGHUP,
 		syscall.SIGINT,
 		syscall.SIGTERM,
 		syscall.SIGQUIT)
-	return abortChan
-}
-
-func runComponent(socket string, opts *componentsOpts, abortChan chan struct{}, onFinish *sync.WaitGroup) error {
-	// remove socket if it is already created.
-	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
-		return err
-	}
-
-	svcLogger.Infof("using socket defined at '%s'", socket)
-
-	lis, err := net.Listen("unix", socket)
-	if err != nil {
-		return err
-	}
+	defer cancel()
 
-	defer lis.Close()
-
-	server := grpc.NewServer()
-
-	if err = opts.apply(server); err != nil {
-		return err
-	}
-	go func() {
-		defer onFinish.Done()
-		<-abortChan
-		lis.Close()
-	}()
-
-	reflection.Register(server)
-	return server.Serve(lis)
+	return RunContext(ctx)
 }
 
-// Run starts the component server.
-func Run() error {
-	socketFolder, ok := os.LookupEnv(unixSocketFolderPathEnvVar)
-	if !ok {
-		socketFolder, ok = os.LookupEnv(fallbackUnixSocketFolderPathEnvVar)
-		if !ok {
-			socketFolder = defaultSocketFolder
-		}
-	}
-	if len(factories) == 0 {
-		return ErrNoComponentsRegistered
-	}
-	done := make(chan struct{}, len(factories))
-	abort := makeAbortChan(done)
-	var cleanupGroup sync.WaitGroup
+// RunContext starts the component server and blocks until the given context is done.
+// When the context is done it stops accepting new calls, stops handing new messages to the streams
+// and waits for in-flight calls and pending acks to settle before returning.
+// The returned error joins a *ComponentError for each component that could not be served.
+func RunContext(ctx context.Context, opts ...ServerOption) error {
+	server := NewServer(opts...)
 
-	for component := range factories {
-		socket := filepath.Join(socketFolder, component+".sock")
-		cleanupGroup.Add(1)
-		go func(opts *componentsOpts) {
-			err := runComponent(socket, opts, abort, &cleanupGroup)
-			if err != nil {
-				svcLogger.Errorf("aborting due to an error %v", err)
-				done <- struct{}{}
-			}
-		}(factories[component])
+	defaultServer.mu.Lock()
+	for name, cmpOpts := range defaultServer.factories {
+		server.factories[name] = cmpOpts
 	}
+	defaultServer.mu.Unlock()
 
-	select {
-	case <-done:
-		cleanupGroup.Wait()
-		return nil
-	case <-abort:
-		cleanupGroup.Wait()
-		return nil
-	}
+	return server.Serve(ctx)
 }
 
 // MustRun same as run but panics on error
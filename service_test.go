@@ -14,8 +14,12 @@ limitations under the License.
 package dapr
 
 import (
+	"context"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/dapr-sandbox/components-go-sdk/state/v1"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -28,4 +32,30 @@ func TestServiceRun(t *testing.T) {
 		t.Setenv(fallbackUnixSocketFolderPathEnvVar, "/tmp")
 		assert.NotNil(t, Run())
 	})
+
+	t.Run("run context should stop serving when context is done", func(t *testing.T) {
+		const fakeComponent = "fake-run-context"
+		socketFolder := socketTempDir(t)
+		t.Setenv(unixSocketFolderPathEnvVar, socketFolder)
+		Register(fakeComponent, WithStateStore(func() state.Store { return &fakeStateStore{} }))
+		t.Cleanup(func() {
+			delete(defaultServer.factories, fakeComponent)
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		runErr := make(chan error, 1)
+		go func() {
+			runErr <- RunContext(ctx, WithDrainTimeout(time.Second))
+		}()
+
+		waitForSocket(t, filepath.Join(socketFolder, fakeComponent+".sock"))
+
+		cancel()
+		select {
+		case err := <-runErr:
+			assert.Nil(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("run context should return after context is done")
+		}
+	})
 }
@@ -0,0 +1,99 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"context"
+	"strconv"
+
+	contribState "github.com/dapr/components-contrib/state"
+	"github.com/dapr/kit/logger"
+
+	sdkerrors "github.com/dapr-sandbox/components-go-sdk/errors"
+	"github.com/dapr-sandbox/components-go-sdk/internal"
+)
+
+// ParallelismMetadataKey is the request metadata key used to request the parallelism of bulk operations,
+// when the bulk request options don't. The first item that has it sets the parallelism of the whole request.
+const ParallelismMetadataKey = "parallelism"
+
+var bulkLogger = logger.NewLogger("state-component-bulk")
+
+// emulatedBulkStore runs the bulk operations as concurrent single key operations.
+type emulatedBulkStore struct {
+	*wrappedStore
+}
+
+// EmulateBulk returns a store whose bulk operations call the single key operations of the given one concurrently,
+// up to the parallelism of each request. BulkGet reports the error of each key on its item, and BulkSet and BulkDelete
+// report the failed keys by returning the joined errors of each failed operation. Use it for stores that only implement
+// single key operations.
+func EmulateBulk(store Store) Store {
+	return &emulatedBulkStore{wrappedStore: &wrappedStore{Store: store}}
+}
+
+func (s *emulatedBulkStore) BulkGet(ctx context.Context, req []contribState.GetRequest, opts contribState.BulkGetOpts) ([]contribState.BulkGetResponse, error) {
+	return contribState.DoBulkGet(ctx, req, opts, recoverGet(s.Store.Get))
+}
+
+func (s *emulatedBulkStore) BulkSet(ctx context.Context, req []contribState.SetRequest, opts contribState.BulkStoreOpts) error {
+	return contribState.DoBulkSetDelete(ctx, req, recoverWrite(s.Store.Set), opts)
+}
+
+func (s *emulatedBulkStore) BulkDelete(ctx context.Context, req []contribState.DeleteRequest, opts contribState.BulkStoreOpts) error {
+	return contribState.DoBulkSetDelete(ctx, req, recoverWrite(s.Store.Delete), opts)
+}
+
+// recoverGet returns the given get function recovering from its panics, which are returned as internal errors.
+// bulk operations call it from their own goroutines, which the panic recovery of the gRPC server does not cover.
+func recoverGet(get func(context.Context, *contribState.GetRequest) (*contribState.GetResponse, error)) func(context.Context, *contribState.GetRequest) (*contribState.GetResponse, error) {
+	return func(ctx context.Context, req *contribState.GetRequest) (resp *contribState.GetResponse, err error) {
+		defer internal.Recover(ctx, bulkLogger, &err)
+		return get(ctx, req)
+	}
+}
+
+// recoverWrite is like recoverGet for set and delete functions.
+func recoverWrite[T contribState.SetRequest | contribState.DeleteRequest](write func(context.Context, *T) error) func(context.Context, *T) error {
+	return func(ctx context.Context, req *T) (err error) {
+		defer internal.Recover(ctx, bulkLogger, &err)
+		return write(ctx, req)
+	}
+}
+
+// bulkParallelism returns the parallelism of a bulk request, the one requested through its options or the metadata of its items,
+// or the registration default otherwise. The parallelism is capped by the registration maximum, if any.
+func (s *store) bulkParallelism(requested int64, metadata ...map[string]string) (int, error) {
+	parallelism := int(requested)
+	if parallelism <= 0 {
+		for _, md := range metadata {
+			value, ok := md[ParallelismMetadataKey]
+			if !ok {
+				continue
+			}
+			var err error
+			if parallelism, err = strconv.Atoi(value); err != nil {
+				return 0, sdkerrors.InvalidArgument("invalid %s metadata %q: %w", ParallelismMetadataKey, value, err)
+			}
+			break
+		}
+	}
+	if parallelism <= 0 {
+		parallelism = s.opts.bulkParallelism
+	}
+	if maxParallelism := s.opts.maxBulkParallelism; maxParallelism > 0 && (parallelism <= 0 || parallelism > maxParallelism) {
+		parallelism = maxParallelism
+	}
+	return parallelism, nil
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	contribState "github.com/dapr/components-contrib/state"
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeBulkStore records the parallelism of the bulk requests it receives.
+type fakeBulkStore struct {
+	Store
+	parallelism int
+}
+
+func (f *fakeBulkStore) BulkGet(_ context.Context, _ []contribState.GetRequest, opts contribState.BulkGetOpts) ([]contribState.BulkGetResponse, error) {
+	f.parallelism = opts.Parallelism
+	return nil, nil
+}
+
+func (f *fakeBulkStore) BulkSet(_ context.Context, _ []contribState.SetRequest, opts contribState.BulkStoreOpts) error {
+	f.parallelism = opts.Parallelism
+	return nil
+}
+
+func (f *fakeBulkStore) BulkDelete(_ context.Context, _ []contribState.DeleteRequest, opts contribState.BulkStoreOpts) error {
+	f.parallelism = opts.Parallelism
+	return nil
+}
+
+// fakeSlowStore tracks the concurrent single key operations it serves.
+type fakeSlowStore struct {
+	*fakeMemStore
+	running    atomic.Int64
+	maxRunning atomic.Int64
+	getErr     error
+}
+
+func (f *fakeSlowStore) track() func() {
+	running := f.running.Add(1)
+	for {
+		maxRunning := f.maxRunning.Load()
+		if running <= maxRunning || f.maxRunning.CompareAndSwap(maxRunning, running) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	return func() { f.running.Add(-1) }
+}
+
+func (f *fakeSlowStore) Get(ctx context.Context, req *contribState.GetRequest) (*contribState.GetResponse, error) {
+	defer f.track()()
+	if req.Key == "fail" {
+		return nil, f.getErr
+	}
+	return f.fakeMemStore.Get(ctx, req)
+}
+
+func (f *fakeSlowStore) Set(ctx context.Context, req *contribState.SetRequest) error {
+	defer f.track()()
+	return f.fakeMemStore.Set(ctx, req)
+}
+
+// fakePanicStore panics on every single key operation.
+type fakePanicStore struct {
+	Store
+}
+
+func (f *fakePanicStore) Features() []contribState.Feature {
+	return nil
+}
+
+func (f *fakePanicStore) Get(context.Context, *contribState.GetRequest) (*contribState.GetResponse, error) {
+	panic("fake-get-panic")
+}
+
+func (f *fakePanicStore) Set(context.Context, *contribState.SetRequest) error {
+	panic("fake-set-panic")
+}
+
+func (f *fakePanicStore) Delete(context.Context, *contribState.DeleteRequest) error {
+	panic("fake-delete-panic")
+}
+
+func TestBulkParallelism(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		options  int64
+		metadata map[string]string
+		expected int
+	}{
+		{name: "default", expected: defaultBulkParallelism},
+		{name: "requested through options", options: 3, expected: 3},
+		{name: "requested through metadata", metadata: map[string]string{ParallelismMetadataKey: "4"}, expected: 4},
+		{name: "requested through options and metadata", options: 3, metadata: map[string]string{ParallelismMetadataKey: "4"}, expected: 3},
+		{name: "registration default", opts: []Option{WithBulkParallelism(5)}, expected: 5},
+		{name: "capped", opts: []Option{WithMaxBulkParallelism(2)}, options: 3, expected: 2},
+		{name: "unlimited default capped", opts: []Option{WithBulkParallelism(0), WithMaxBulkParallelism(2)}, expected: 2},
+		{name: "unlimited default", opts: []Option{WithBulkParallelism(0)}, expected: 0},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name+" parallelism should be passed to the store", func(t *testing.T) {
+			impl := &fakeBulkStore{}
+			s := &store{getInstance: func(context.Context) (Store, error) { return impl, nil }, opts: newOptions(tt.opts...)}
+
+			_, err := s.BulkGet(context.Background(), &proto.BulkGetRequest{
+				Items:   []*proto.GetRequest{{Key: "a"}, {Key: "b", Metadata: tt.metadata}},
+				Options: &proto.BulkGetRequestOptions{Parallelism: tt.options},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, impl.parallelism)
+
+			impl.parallelism = -1
+			_, err = s.BulkSet(context.Background(), &proto.BulkSetRequest{
+				Items:   []*proto.SetRequest{{Key: "a", Metadata: tt.metadata}},
+				Options: &proto.BulkSetRequestOptions{Parallelism: tt.options},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, impl.parallelism)
+
+			impl.parallelism = -1
+			_, err = s.BulkDelete(context.Background(), &proto.BulkDeleteRequest{
+				Items:   []*proto.DeleteRequest{{Key: "a", Metadata: tt.metadata}},
+				Options: &proto.BulkDeleteRequestOptions{Parallelism: tt.options},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, impl.parallelism)
+		})
+	}
+
+	t.Run("invalid parallelism metadata should return an invalid argument error", func(t *testing.T) {
+		s := &store{getInstance: func(context.Context) (Store, error) { return &fakeBulkStore{}, nil }, opts: newOptions()}
+		_, err := s.BulkGet(context.Background(), &proto.BulkGetRequest{
+			Items: []*proto.GetRequest{{Key: "a", Metadata: map[string]string{ParallelismMetadataKey: "many"}}},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestEmulateBulk(t *testing.T) {
+	t.Run("bulk get should report the error of each key", func(t *testing.T) {
+		impl := &fakeSlowStore{fakeMemStore: newFakeMemStore(map[string][]byte{"a": []byte("1")}), getErr: errors.New("fake-get-err")}
+		store := EmulateBulk(impl)
+
+		items, err := store.BulkGet(context.Background(), []contribState.GetRequest{{Key: "a"}, {Key: "fail"}}, contribState.BulkGetOpts{})
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		assert.Equal(t, "a", items[0].Key)
+		assert.Equal(t, []byte("1"), []byte(items[0].Data))
+		assert.Empty(t, items[0].Error)
+		assert.Equal(t, "fail", items[1].Key)
+		assert.Equal(t, "fake-get-err", items[1].Error)
+	})
+
+	t.Run("bulk operations should not exceed the requested parallelism", func(t *testing.T) {
+		impl := &fakeSlowStore{fakeMemStore: newFakeMemStore(nil)}
+		store := EmulateBulk(impl)
+
+		req := make([]contribState.SetRequest, 20)
+		for idx := range req {
+			req[idx] = contribState.SetRequest{Key: string(rune('a' + idx)), Value: "v"}
+		}
+		require.NoError(t, store.BulkSet(context.Background(), req, contribState.BulkStoreOpts{Parallelism: 4}))
+		assert.Len(t, impl.snapshot(), 20)
+		assert.LessOrEqual(t, impl.maxRunning.Load(), int64(4))
+		assert.Greater(t, impl.maxRunning.Load(), int64(1))
+	})
+
+	t.Run("bulk set should report the failed keys", func(t *testing.T) {
+		impl := &fakeSlowStore{fakeMemStore: newFakeMemStore(nil)}
+		fakeErr := errors.New("fake-set-err")
+		impl.failSetOn["b"] = fakeErr
+		store := EmulateBulk(impl)
+
+		err := store.BulkSet(context.Background(), []contribState.SetRequest{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}}, contribState.BulkStoreOpts{})
+		assert.ErrorIs(t, err, fakeErr)
+		assert.Len(t, bulkStoreErrors(err), 1)
+		assert.Equal(t, "b", bulkStoreErrors(err)[0].Key())
+	})
+
+	t.Run("panics of the single key operations should be reported as internal errors", func(t *testing.T) {
+		store := EmulateBulk(&fakePanicStore{})
+		ctx := context.Background()
+
+		items, err := store.BulkGet(ctx, []contribState.GetRequest{{Key: "a"}}, contribState.BulkGetOpts{})
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.Contains(t, items[0].Error, "fake-get-panic")
+
+		err = store.BulkSet(ctx, []contribState.SetRequest{{Key: "a", Value: "1"}}, contribState.BulkStoreOpts{})
+		assert.Equal(t, codes.Internal, status.Code(errors.Unwrap(bulkStoreErrors(err)[0])))
+
+		err = store.BulkDelete(ctx, []contribState.DeleteRequest{{Key: "a"}}, contribState.BulkStoreOpts{})
+		assert.Len(t, bulkStoreErrors(err), 1)
+	})
+}
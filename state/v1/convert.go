@@ -0,0 +1,354 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"fmt"
+
+	contribState "github.com/dapr/components-contrib/state"
+
+	sdkerrors "github.com/dapr-sandbox/components-go-sdk/errors"
+	"github.com/dapr-sandbox/components-go-sdk/internal"
+
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+)
+
+// The conversions below map every field of the proto state messages into their contrib counterparts and back.
+// To functions convert from proto to contrib and From functions convert from contrib to proto.
+// Metadata maps, which carry the TTL (ttlInSeconds) and the TTL expiration (ttlExpireTime) among others, are passed as is.
+
+const (
+	consistencyEventual   = "eventual"
+	consistencyStrong     = "strong"
+	concurrencyLastWrite  = "last-write"
+	concurrencyFirstWrite = "first-write"
+	// contentTypeMetadataKey is the metadata key that overrides the request content type.
+	contentTypeMetadataKey = "contentType"
+)
+
+//nolint:nosnakecase
+var consistencyModels = map[proto.StateOptions_StateConsistency]string{
+	proto.StateOptions_CONSISTENCY_EVENTUAL:    consistencyEventual,
+	proto.StateOptions_CONSISTENCY_STRONG:      consistencyStrong,
+	proto.StateOptions_CONSISTENCY_UNSPECIFIED: "",
+}
+
+// ToConsistency converts the proto consistency into the contrib one, unknown values are converted into an empty string.
+//
+//nolint:nosnakecase
+func ToConsistency(consistency proto.StateOptions_StateConsistency) string {
+	c, ok := consistencyModels[consistency]
+	if !ok {
+		return ""
+	}
+	return c
+}
+
+// FromConsistency converts the contrib consistency into the proto one, unknown values are converted into unspecified.
+//
+//nolint:nosnakecase
+func FromConsistency(consistency string) proto.StateOptions_StateConsistency {
+	for protoConsistency, c := range consistencyModels {
+		if c == consistency {
+			return protoConsistency
+		}
+	}
+	return proto.StateOptions_CONSISTENCY_UNSPECIFIED
+}
+
+//nolint:nosnakecase
+var concurrencyModels = map[proto.StateOptions_StateConcurrency]string{
+	proto.StateOptions_CONCURRENCY_FIRST_WRITE: concurrencyFirstWrite,
+	proto.StateOptions_CONCURRENCY_LAST_WRITE:  concurrencyLastWrite,
+	proto.StateOptions_CONCURRENCY_UNSPECIFIED: "",
+}
+
+// ToConcurrency converts the proto concurrency into the contrib one, unknown values are converted into an empty string.
+//
+//nolint:nosnakecase
+func ToConcurrency(concurrency proto.StateOptions_StateConcurrency) string {
+	c, ok := concurrencyModels[concurrency]
+	if !ok {
+		return ""
+	}
+	return c
+}
+
+// FromConcurrency converts the contrib concurrency into the proto one, unknown values are converted into unspecified.
+//
+//nolint:nosnakecase
+func FromConcurrency(concurrency string) proto.StateOptions_StateConcurrency {
+	for protoConcurrency, c := range concurrencyModels {
+		if c == concurrency {
+			return protoConcurrency
+		}
+	}
+	return proto.StateOptions_CONCURRENCY_UNSPECIFIED
+}
+
+// fromStateOptions converts the contrib concurrency and consistency into proto options, nil when both are unset.
+func fromStateOptions(concurrency, consistency string) *proto.StateOptions {
+	if concurrency == "" && consistency == "" {
+		return nil
+	}
+	return &proto.StateOptions{
+		Concurrency: FromConcurrency(concurrency),
+		Consistency: FromConsistency(consistency),
+	}
+}
+
+// ToETag converts the proto etag into the contrib one.
+func ToETag(etag *proto.Etag) *string {
+	return internal.IfNotNilP(etag, func(f *proto.Etag) string {
+		return f.Value
+	})
+}
+
+// FromETag converts the contrib etag into the proto one.
+func FromETag(etag *string) *proto.Etag {
+	return internal.IfNotNil(etag, func(etagValue *string) *proto.Etag {
+		return &proto.Etag{
+			Value: *etagValue,
+		}
+	})
+}
+
+// toContentType returns the content type pointer, nil when empty.
+func toContentType(contentType string) *string {
+	if contentType == "" {
+		return nil
+	}
+	return &contentType
+}
+
+// ToGetRequest converts the proto get request into the contrib one.
+func ToGetRequest(req *proto.GetRequest) *contribState.GetRequest {
+	return &contribState.GetRequest{
+		Key:      req.Key,
+		Metadata: req.Metadata,
+		Options: contribState.GetStateOption{
+			Consistency: ToConsistency(req.Consistency),
+		},
+	}
+}
+
+// FromGetRequest converts the contrib get request into the proto one.
+func FromGetRequest(req *contribState.GetRequest) *proto.GetRequest {
+	return &proto.GetRequest{
+		Key:         req.Key,
+		Metadata:    req.Metadata,
+		Consistency: FromConsistency(req.Options.Consistency),
+	}
+}
+
+// ToGetResponse converts the proto get response into the contrib one.
+func ToGetResponse(res *proto.GetResponse) *contribState.GetResponse {
+	return &contribState.GetResponse{
+		Data:        res.Data,
+		ETag:        ToETag(res.Etag),
+		Metadata:    res.Metadata,
+		ContentType: toContentType(res.ContentType),
+	}
+}
+
+// FromGetResponse converts the contrib get response into the proto one.
+func FromGetResponse(res *contribState.GetResponse) *proto.GetResponse {
+	return &proto.GetResponse{
+		Data:        res.Data,
+		Etag:        FromETag(res.ETag),
+		ContentType: internal.ZeroValueIfNil(res.ContentType),
+		Metadata:    res.Metadata,
+	}
+}
+
+// ToSetRequest converts the proto set request into the contrib one.
+// the `contentType` metadata overrides the request content type, and values are decoded using the codec
+// registered for their content type, see RegisterCodec. Values that can't be decoded return an invalid argument error.
+func ToSetRequest(req *proto.SetRequest) (*contribState.SetRequest, error) {
+	return toSetRequest(req, true)
+}
+
+// toSetRequest converts the proto set request into the contrib one, decoding the value only when requested.
+func toSetRequest(req *proto.SetRequest, decode bool) (*contribState.SetRequest, error) {
+	var value any = req.Value
+	contentType := toContentType(req.ContentType)
+	if ct, ok := req.Metadata[contentTypeMetadataKey]; ok {
+		contentType = &ct
+	}
+
+	if decode && contentType != nil {
+		if codec, ok := CodecFor(*contentType); ok {
+			v, err := codec.Unmarshal(req.Value)
+			if err != nil {
+				return nil, sdkerrors.InvalidArgument("could not decode the value of key %s as %s: %w", req.Key, *contentType, err)
+			}
+			value = v
+		}
+	}
+
+	return &contribState.SetRequest{
+		Key:         req.Key,
+		Value:       value,
+		ETag:        ToETag(req.Etag),
+		ContentType: contentType,
+		Metadata:    req.Metadata,
+		Options: internal.IfNotNil(req.Options, func(f *proto.StateOptions) contribState.SetStateOption {
+			return contribState.SetStateOption{
+				Concurrency: ToConcurrency(f.Concurrency),
+				Consistency: ToConsistency(f.Consistency),
+			}
+		}),
+	}, nil
+}
+
+// encodeValue returns the bytes of a set request value, values that are not byte slices are encoded
+// using the codec registered for their content type, or as json if there is none.
+func encodeValue(value any, contentType *string) ([]byte, error) {
+	if bytes, ok := value.([]byte); ok {
+		return bytes, nil
+	}
+	var codec Codec = internal.JSONCodec{}
+	if contentType != nil {
+		if contentTypeCodec, ok := CodecFor(*contentType); ok {
+			codec = contentTypeCodec
+		}
+	}
+	return codec.Marshal(value)
+}
+
+// FromSetRequest converts the contrib set request into the proto one.
+// values that are not byte slices are encoded using the codec registered for their content type, or as json if there is none.
+func FromSetRequest(req *contribState.SetRequest) (*proto.SetRequest, error) {
+	value, err := encodeValue(req.Value, req.ContentType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.SetRequest{
+		Key:         req.Key,
+		Value:       value,
+		Etag:        FromETag(req.ETag),
+		Metadata:    req.Metadata,
+		Options:     fromStateOptions(req.Options.Concurrency, req.Options.Consistency),
+		ContentType: internal.ZeroValueIfNil(req.ContentType),
+	}, nil
+}
+
+// ToDeleteRequest converts the proto delete request into the contrib one.
+func ToDeleteRequest(req *proto.DeleteRequest) *contribState.DeleteRequest {
+	return &contribState.DeleteRequest{
+		Key:      req.Key,
+		ETag:     ToETag(req.Etag),
+		Metadata: req.Metadata,
+		Options: internal.IfNotNil(req.Options, func(f *proto.StateOptions) contribState.DeleteStateOption {
+			return contribState.DeleteStateOption{
+				Concurrency: ToConcurrency(f.Concurrency),
+				Consistency: ToConsistency(f.Consistency),
+			}
+		}),
+	}
+}
+
+// FromDeleteRequest converts the contrib delete request into the proto one.
+func FromDeleteRequest(req *contribState.DeleteRequest) *proto.DeleteRequest {
+	return &proto.DeleteRequest{
+		Key:      req.Key,
+		Etag:     FromETag(req.ETag),
+		Metadata: req.Metadata,
+		Options:  fromStateOptions(req.Options.Concurrency, req.Options.Consistency),
+	}
+}
+
+// ToBulkGetResponse converts the proto bulk state item into the contrib one.
+func ToBulkGetResponse(item *proto.BulkStateItem) contribState.BulkGetResponse {
+	return contribState.BulkGetResponse{
+		Key:         item.Key,
+		Data:        item.Data,
+		ETag:        ToETag(item.Etag),
+		Metadata:    item.Metadata,
+		Error:       item.Error,
+		ContentType: toContentType(item.ContentType),
+	}
+}
+
+// FromBulkGetResponse converts the contrib bulk get response into the proto bulk state item.
+func FromBulkGetResponse(item contribState.BulkGetResponse) *proto.BulkStateItem {
+	return &proto.BulkStateItem{
+		Key:         item.Key,
+		Data:        item.Data,
+		Etag:        FromETag(item.ETag),
+		Error:       item.Error,
+		Metadata:    item.Metadata,
+		ContentType: internal.ZeroValueIfNil(item.ContentType),
+	}
+}
+
+// ToQueryItem converts the proto query item into the contrib one.
+func ToQueryItem(item *proto.QueryItem) contribState.QueryItem {
+	return contribState.QueryItem{
+		Key:         item.Key,
+		Data:        item.Data,
+		ETag:        ToETag(item.Etag),
+		Error:       item.Error,
+		ContentType: toContentType(item.ContentType),
+	}
+}
+
+// FromQueryItem converts the contrib query item into the proto one.
+func FromQueryItem(item contribState.QueryItem) *proto.QueryItem {
+	return &proto.QueryItem{
+		Key:         item.Key,
+		Data:        item.Data,
+		Etag:        FromETag(item.ETag),
+		Error:       item.Error,
+		ContentType: internal.ZeroValueIfNil(item.ContentType),
+	}
+}
+
+// ToTransactionalStateOperation converts the proto transactional operation into the contrib one.
+func ToTransactionalStateOperation(op *proto.TransactionalStateOperation) (contribState.TransactionalStateOperation, error) {
+	return toTransactionalStateOperation(op, true)
+}
+
+// toTransactionalStateOperation converts the proto transactional operation into the contrib one, decoding set values only when requested.
+func toTransactionalStateOperation(op *proto.TransactionalStateOperation, decode bool) (contribState.TransactionalStateOperation, error) {
+	if opDelete := op.GetDelete(); opDelete != nil {
+		return *ToDeleteRequest(opDelete), nil
+	}
+	set, err := toSetRequest(op.GetSet(), decode)
+	if err != nil {
+		return nil, err
+	}
+	return *set, nil
+}
+
+// FromTransactionalStateOperation converts the contrib transactional operation into the proto one.
+func FromTransactionalStateOperation(op contribState.TransactionalStateOperation) (*proto.TransactionalStateOperation, error) {
+	switch req := op.(type) {
+	case contribState.DeleteRequest:
+		return &proto.TransactionalStateOperation{
+			Request: &proto.TransactionalStateOperation_Delete{Delete: FromDeleteRequest(&req)},
+		}, nil
+	case contribState.SetRequest:
+		set, err := FromSetRequest(&req)
+		if err != nil {
+			return nil, err
+		}
+		return &proto.TransactionalStateOperation{
+			Request: &proto.TransactionalStateOperation_Set{Set: set},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported transactional operation %T", op)
+	}
+}
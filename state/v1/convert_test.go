@@ -0,0 +1,290 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"strings"
+	"testing"
+
+	contribState "github.com/dapr/components-contrib/state"
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+
+	sdkerrors "github.com/dapr-sandbox/components-go-sdk/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// assertAllFieldsSet asserts that the test message sets all of its fields, so the round trip covers every field.
+func assertAllFieldsSet(t *testing.T, msg protobuf.Message) {
+	t.Helper()
+	fields := msg.ProtoReflect().Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if field.ContainingOneof() != nil {
+			continue
+		}
+		assert.True(t, msg.ProtoReflect().Has(field), "field %s should be set", field.Name())
+	}
+}
+
+func ptr(value string) *string {
+	return &value
+}
+
+// upperCodec decodes values as upper case strings and encodes them back as lower case.
+type upperCodec struct{}
+
+func (upperCodec) Marshal(v any) ([]byte, error) {
+	return []byte(strings.ToLower(v.(string))), nil
+}
+
+func (upperCodec) Unmarshal(data []byte) (any, error) {
+	return strings.ToUpper(string(data)), nil
+}
+
+//nolint:nosnakecase
+func TestConversions(t *testing.T) {
+	ttlMetadata := map[string]string{"ttlInSeconds": "10"}
+	ttlExpireMetadata := map[string]string{"ttlExpireTime": "2023-01-01T00:00:00Z"}
+	etag := &proto.Etag{Value: "etag-1"}
+	options := &proto.StateOptions{
+		Concurrency: proto.StateOptions_CONCURRENCY_FIRST_WRITE,
+		Consistency: proto.StateOptions_CONSISTENCY_STRONG,
+	}
+
+	tests := []struct {
+		name string
+		// msg is the proto message being converted, it should set all fields.
+		msg protobuf.Message
+		// expected is the expected contrib counterpart.
+		expected any
+	}{
+		{
+			name: "get request",
+			msg:  &proto.GetRequest{Key: "key", Metadata: ttlMetadata, Consistency: proto.StateOptions_CONSISTENCY_STRONG},
+			expected: &contribState.GetRequest{
+				Key:      "key",
+				Metadata: ttlMetadata,
+				Options:  contribState.GetStateOption{Consistency: "strong"},
+			},
+		},
+		{
+			name: "set request",
+			msg: &proto.SetRequest{
+				Key:         "key",
+				Value:       []byte(`{"a":1}`),
+				Etag:        etag,
+				Metadata:    ttlMetadata,
+				Options:     options,
+				ContentType: "application/json",
+			},
+			expected: &contribState.SetRequest{
+				Key:         "key",
+				Value:       map[string]any{"a": float64(1)},
+				ETag:        ptr("etag-1"),
+				Metadata:    ttlMetadata,
+				Options:     contribState.SetStateOption{Concurrency: "first-write", Consistency: "strong"},
+				ContentType: ptr("application/json"),
+			},
+		},
+		{
+			name: "delete request",
+			msg: &proto.DeleteRequest{
+				Key:      "key",
+				Etag:     etag,
+				Metadata: ttlMetadata,
+				Options: &proto.StateOptions{
+					Concurrency: proto.StateOptions_CONCURRENCY_LAST_WRITE,
+					Consistency: proto.StateOptions_CONSISTENCY_EVENTUAL,
+				},
+			},
+			expected: &contribState.DeleteRequest{
+				Key:      "key",
+				ETag:     ptr("etag-1"),
+				Metadata: ttlMetadata,
+				Options:  contribState.DeleteStateOption{Concurrency: "last-write", Consistency: "eventual"},
+			},
+		},
+		{
+			name: "get response",
+			msg:  &proto.GetResponse{Data: []byte("data"), Etag: etag, Metadata: ttlExpireMetadata, ContentType: "text/plain"},
+			expected: &contribState.GetResponse{
+				Data:        []byte("data"),
+				ETag:        ptr("etag-1"),
+				Metadata:    ttlExpireMetadata,
+				ContentType: ptr("text/plain"),
+			},
+		},
+		{
+			name: "bulk state item",
+			msg:  &proto.BulkStateItem{Key: "key", Data: []byte("data"), Etag: etag, Error: "err", Metadata: ttlExpireMetadata, ContentType: "text/plain"},
+			expected: contribState.BulkGetResponse{
+				Key:         "key",
+				Data:        []byte("data"),
+				ETag:        ptr("etag-1"),
+				Metadata:    ttlExpireMetadata,
+				Error:       "err",
+				ContentType: ptr("text/plain"),
+			},
+		},
+		{
+			name: "query item",
+			msg:  &proto.QueryItem{Key: "key", Data: []byte("data"), Etag: etag, Error: "err", ContentType: "text/plain"},
+			expected: contribState.QueryItem{
+				Key:         "key",
+				Data:        []byte("data"),
+				ETag:        ptr("etag-1"),
+				Error:       "err",
+				ContentType: ptr("text/plain"),
+			},
+		},
+		{
+			name: "transactional delete operation",
+			msg: &proto.TransactionalStateOperation{
+				Request: &proto.TransactionalStateOperation_Delete{Delete: &proto.DeleteRequest{Key: "key", Etag: etag, Metadata: ttlMetadata, Options: options}},
+			},
+			expected: contribState.DeleteRequest{
+				Key:      "key",
+				ETag:     ptr("etag-1"),
+				Metadata: ttlMetadata,
+				Options:  contribState.DeleteStateOption{Concurrency: "first-write", Consistency: "strong"},
+			},
+		},
+		{
+			name: "transactional set operation",
+			msg: &proto.TransactionalStateOperation{
+				Request: &proto.TransactionalStateOperation_Set{Set: &proto.SetRequest{Key: "key", Value: []byte("value"), Metadata: ttlMetadata}},
+			},
+			expected: contribState.SetRequest{
+				Key:      "key",
+				Value:    []byte("value"),
+				Metadata: ttlMetadata,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name+" should round trip", func(t *testing.T) {
+			assertAllFieldsSet(t, tt.msg)
+
+			contrib, back, err := roundTrip(tt.msg)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, contrib)
+			assert.True(t, protobuf.Equal(tt.msg, back), "expected %v, got %v", tt.msg, back)
+		})
+	}
+
+	t.Run("unspecified options should round trip as nil options", func(t *testing.T) {
+		req := &proto.SetRequest{Key: "key", Value: []byte("value"), Options: &proto.StateOptions{}}
+		setReq, err := ToSetRequest(req)
+		require.NoError(t, err)
+		back, err := FromSetRequest(setReq)
+		require.NoError(t, err)
+		assert.Nil(t, back.Options)
+	})
+
+	t.Run("content type metadata should override the request content type", func(t *testing.T) {
+		req, err := ToSetRequest(&proto.SetRequest{
+			Value:       []byte(`{"a":1}`),
+			ContentType: "text/plain",
+			Metadata:    map[string]string{"contentType": "application/json"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "application/json", *req.ContentType)
+		assert.Equal(t, map[string]any{"a": float64(1)}, req.Value)
+	})
+
+	t.Run("values that can't be decoded should return an invalid argument error", func(t *testing.T) {
+		_, err := ToSetRequest(&proto.SetRequest{Key: "key", Value: []byte(`{"a":`), ContentType: "application/json"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(sdkerrors.ToGRPC(err)))
+
+		_, err = ToTransactionalStateOperation(&proto.TransactionalStateOperation{
+			Request: &proto.TransactionalStateOperation_Set{Set: &proto.SetRequest{Key: "key", Value: []byte(`{"a":`), ContentType: "application/json"}},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(sdkerrors.ToGRPC(err)))
+	})
+
+	t.Run("suffixed json content types should be decoded as json", func(t *testing.T) {
+		req, err := ToSetRequest(&proto.SetRequest{Value: []byte(`{"a":1}`), ContentType: "application/merge-patch+json; charset=utf-8"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"a": float64(1)}, req.Value)
+	})
+
+	t.Run("registered codecs should be used to decode and encode values", func(t *testing.T) {
+		RegisterCodec("application/x-upper", upperCodec{})
+
+		req, err := ToSetRequest(&proto.SetRequest{Value: []byte("value"), ContentType: "application/x-upper"})
+		require.NoError(t, err)
+		assert.Equal(t, "VALUE", req.Value)
+
+		back, err := FromSetRequest(req)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("value"), back.Value)
+	})
+
+	t.Run("raw values should not be decoded", func(t *testing.T) {
+		req, err := toSetRequest(&proto.SetRequest{Value: []byte(`{"a":`), ContentType: "application/json"}, false)
+		require.NoError(t, err)
+		assert.Equal(t, []byte(`{"a":`), req.Value)
+	})
+
+	t.Run("unknown options should be converted into empty and unspecified values", func(t *testing.T) {
+		assert.Empty(t, ToConsistency(proto.StateOptions_StateConsistency(42)))
+		assert.Empty(t, ToConcurrency(proto.StateOptions_StateConcurrency(42)))
+		assert.Equal(t, proto.StateOptions_CONSISTENCY_UNSPECIFIED, FromConsistency("unknown"))
+		assert.Equal(t, proto.StateOptions_CONCURRENCY_UNSPECIFIED, FromConcurrency("unknown"))
+	})
+}
+
+// roundTrip converts the given proto message into its contrib counterpart and back.
+func roundTrip(msg protobuf.Message) (contrib any, back protobuf.Message, err error) {
+	switch m := msg.(type) {
+	case *proto.GetRequest:
+		req := ToGetRequest(m)
+		return req, FromGetRequest(req), nil
+	case *proto.SetRequest:
+		req, err := ToSetRequest(m)
+		if err != nil {
+			return nil, nil, err
+		}
+		back, err := FromSetRequest(req)
+		return req, back, err
+	case *proto.DeleteRequest:
+		req := ToDeleteRequest(m)
+		return req, FromDeleteRequest(req), nil
+	case *proto.GetResponse:
+		res := ToGetResponse(m)
+		return res, FromGetResponse(res), nil
+	case *proto.BulkStateItem:
+		item := ToBulkGetResponse(m)
+		return item, FromBulkGetResponse(item), nil
+	case *proto.QueryItem:
+		item := ToQueryItem(m)
+		return item, FromQueryItem(item), nil
+	case *proto.TransactionalStateOperation:
+		op, err := ToTransactionalStateOperation(m)
+		if err != nil {
+			return nil, nil, err
+		}
+		back, err := FromTransactionalStateOperation(op)
+		return op, back, err
+	default:
+		return nil, nil, nil
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	contribState "github.com/dapr/components-contrib/state"
+
+	sdkerrors "github.com/dapr-sandbox/components-go-sdk/errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// etagField is the field daprd expects on the bad request violation of etag errors.
+	etagField = "etag"
+	// affectedRowsMetadataKey and expectedRowsMetadataKey are the error info metadata keys daprd expects on bulk delete mismatch errors.
+	affectedRowsMetadataKey = "affected"
+	expectedRowsMetadataKey = "expected"
+	// bulkDeleteRowMismatchReason is the error info reason of bulk delete mismatch errors.
+	bulkDeleteRowMismatchReason = "BULK_DELETE_ROW_MISMATCH"
+	// bulkDeleteRowMismatchFormat is the message format of contrib bulk delete mismatch errors.
+	bulkDeleteRowMismatchFormat = "delete affected only %d rows, expected %d"
+)
+
+// etagErrorCodes are the gRPC codes daprd maps back to each etag error kind.
+var etagErrorCodes = map[contribState.ETagErrorKind]codes.Code{
+	contribState.ETagMismatch: codes.FailedPrecondition,
+	contribState.ETagInvalid:  codes.InvalidArgument,
+}
+
+// toGRPCError translates the component errors into the gRPC status errors daprd expects.
+// besides the contrib state errors, it translates the typed errors of the errors package.
+// errors that have no special meaning for daprd are returned as is.
+func toGRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var etagErr *contribState.ETagError
+	if errors.As(err, &etagErr) {
+		return etagStatusError(etagErr, etagDescription(etagErr))
+	}
+
+	var mismatchErr *contribState.BulkDeleteRowMismatchError
+	if errors.As(err, &mismatchErr) {
+		return bulkDeleteRowMismatchStatusError(mismatchErr)
+	}
+
+	return sdkerrors.ToGRPC(err)
+}
+
+// toBulkGRPCError is like toGRPCError but it reports the etag failures of each key
+// when the component returns the bulk errors of each operation, as contrib state.DoBulkSetDelete does.
+func toBulkGRPCError(err error) error {
+	bulkErrs := bulkStoreErrors(err)
+
+	var (
+		etagErr  *contribState.ETagError
+		failures []string
+	)
+	for _, bulkErr := range bulkErrs {
+		keyETagErr := bulkErr.ETagError()
+		if keyETagErr == nil {
+			continue
+		}
+		if etagErr == nil {
+			etagErr = keyETagErr
+		}
+		failures = append(failures, fmt.Sprintf("%s: %s", bulkErr.Key(), etagDescription(keyETagErr)))
+	}
+
+	if etagErr == nil {
+		return toGRPCError(err)
+	}
+	return etagStatusError(etagErr, strings.Join(failures, "; "))
+}
+
+// bulkStoreErrors returns the bulk store errors contained in the given, possibly joined, error.
+func bulkStoreErrors(err error) []contribState.BulkStoreError {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var bulkErrs []contribState.BulkStoreError
+		for _, e := range joined.Unwrap() {
+			bulkErrs = append(bulkErrs, bulkStoreErrors(e)...)
+		}
+		return bulkErrs
+	}
+	var bulkErr contribState.BulkStoreError
+	if errors.As(err, &bulkErr) {
+		return []contribState.BulkStoreError{bulkErr}
+	}
+	return nil
+}
+
+// etagDescription returns the etag error cause, daprd adds the etag error kind prefix on its own.
+func etagDescription(etagErr *contribState.ETagError) string {
+	if cause := etagErr.Unwrap(); cause != nil {
+		return cause.Error()
+	}
+	return ""
+}
+
+// etagStatusError builds the status of etag errors carrying the bad request etag violation daprd expects.
+func etagStatusError(etagErr *contribState.ETagError, description string) error {
+	code, ok := etagErrorCodes[etagErr.Kind()]
+	if !ok {
+		return etagErr
+	}
+	st, err := status.New(code, etagErr.Error()).WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{
+			Field:       etagField,
+			Description: description,
+		}},
+	})
+	if err != nil {
+		return status.Error(code, etagErr.Error())
+	}
+	return st.Err()
+}
+
+// bulkDeleteRowMismatchStatusError builds the status of bulk delete mismatch errors carrying the error info daprd expects.
+func bulkDeleteRowMismatchStatusError(mismatchErr *contribState.BulkDeleteRowMismatchError) error {
+	// contrib does not expose the rows count, so they are parsed back from the error message.
+	var expected, affected uint64
+	if _, err := fmt.Sscanf(mismatchErr.Error(), bulkDeleteRowMismatchFormat, &affected, &expected); err != nil {
+		return mismatchErr
+	}
+	st, err := status.New(codes.Internal, mismatchErr.Error()).WithDetails(&errdetails.ErrorInfo{
+		Reason: bulkDeleteRowMismatchReason,
+		Metadata: map[string]string{
+			expectedRowsMetadataKey: strconv.FormatUint(expected, 10),
+			affectedRowsMetadataKey: strconv.FormatUint(affected, 10),
+		},
+	})
+	if err != nil {
+		return status.Error(codes.Internal, mismatchErr.Error())
+	}
+	return st.Err()
+}
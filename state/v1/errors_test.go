@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"errors"
+	"testing"
+
+	contribState "github.com/dapr/components-contrib/state"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// etagViolation returns the code and the etag violation description of the given status error.
+func etagViolation(t *testing.T, err error) (codes.Code, string) {
+	t.Helper()
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Len(t, st.Details(), 1)
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+	require.Len(t, badRequest.FieldViolations, 1)
+	assert.Equal(t, etagField, badRequest.FieldViolations[0].Field)
+	return st.Code(), badRequest.FieldViolations[0].Description
+}
+
+func TestToGRPCError(t *testing.T) {
+	t.Run("nil and unknown errors should be returned as is", func(t *testing.T) {
+		fakeErr := errors.New("fake-err")
+		assert.Nil(t, toGRPCError(nil))
+		assert.Equal(t, fakeErr, toGRPCError(fakeErr))
+	})
+
+	t.Run("etag mismatch should be a failed precondition with the etag violation", func(t *testing.T) {
+		code, description := etagViolation(t, toGRPCError(contribState.NewETagError(contribState.ETagMismatch, errors.New("fake-mismatch"))))
+		assert.Equal(t, codes.FailedPrecondition, code)
+		assert.Equal(t, "fake-mismatch", description)
+	})
+
+	t.Run("invalid etag should be an invalid argument with the etag violation", func(t *testing.T) {
+		code, description := etagViolation(t, toGRPCError(contribState.NewETagError(contribState.ETagInvalid, nil)))
+		assert.Equal(t, codes.InvalidArgument, code)
+		assert.Empty(t, description)
+	})
+
+	t.Run("bulk delete row mismatch should carry the expected and affected rows", func(t *testing.T) {
+		st, ok := status.FromError(toGRPCError(contribState.NewBulkDeleteRowMismatchError(3, 1)))
+		require.True(t, ok)
+		assert.Equal(t, codes.Internal, st.Code())
+		require.Len(t, st.Details(), 1)
+		errorInfo, ok := st.Details()[0].(*errdetails.ErrorInfo)
+		require.True(t, ok)
+		assert.Equal(t, map[string]string{"expected": "3", "affected": "1"}, errorInfo.Metadata)
+	})
+
+	t.Run("bulk errors should report the etag failures of each key", func(t *testing.T) {
+		err := toBulkGRPCError(errors.Join(
+			contribState.NewBulkStoreError("a", contribState.NewETagError(contribState.ETagMismatch, errors.New("fake-mismatch-a"))),
+			contribState.NewBulkStoreError("b", errors.New("fake-err")),
+			contribState.NewBulkStoreError("c", contribState.NewETagError(contribState.ETagMismatch, errors.New("fake-mismatch-c"))),
+		))
+		code, description := etagViolation(t, err)
+		assert.Equal(t, codes.FailedPrecondition, code)
+		assert.Equal(t, "a: fake-mismatch-a; c: fake-mismatch-c", description)
+	})
+
+	t.Run("bulk errors without etag failures should be returned as is", func(t *testing.T) {
+		fakeErr := errors.Join(contribState.NewBulkStoreError("a", errors.New("fake-err")))
+		assert.Equal(t, fakeErr, toBulkGRPCError(fakeErr))
+	})
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	contribState "github.com/dapr/components-contrib/state"
+
+	"github.com/dapr-sandbox/components-go-sdk/internal"
+)
+
+// etagLength is the length of the generated etags, the hex encoding of 8 random bytes.
+const etagLength = 16
+
+// etagEnvelopePrefix marks the values stored along with their etag, it is followed by the etag.
+var etagEnvelopePrefix = []byte("\x00dapr-etag\x00")
+
+// etagStore generates and checks the etags of the items on top of a store that does not support them.
+type etagStore struct {
+	*wrappedStore
+	locker *internal.KeyLocker
+}
+
+// EmulateETags returns a store that supports etags on top of the given one, which is returned as is when it reports the etag feature.
+// A new etag is generated and stored along with the value each time an item is set, so the underlying store receives the values as bytes,
+// and returned on reads. Sets and deletes that carry an etag, or that request first-write concurrency, are checked against the current etag
+// of their item while holding a lock on its key, failing with etag errors otherwise. A first-write set without etag only succeeds when the
+// item does not exist. Checks are only atomic for the writes made through the returned store. Transactions of an underlying transactional
+// store are forwarded to it once the etags of their operations are checked. Queries are only served when the underlying store implements
+// Lister, since native queries would run over the stored envelopes.
+func EmulateETags(store Store) Store {
+	if contribState.FeatureETag.IsPresent(store.Features()) {
+		return store
+	}
+	return &etagStore{
+		wrappedStore: &wrappedStore{Store: store},
+		locker:       internal.NewKeyLocker(),
+	}
+}
+
+func (s *etagStore) Features() []contribState.Feature {
+	return append(withoutNativeQueries(s.Store.Features()), contribState.FeatureETag)
+}
+
+func (s *etagStore) Set(ctx context.Context, req *contribState.SetRequest) error {
+	unlock, err := s.locker.Lock(ctx, req.Key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.check(ctx, req.Key, req.ETag, req.Options.Concurrency, true); err != nil {
+		return err
+	}
+	envelope, err := s.envelope(req)
+	if err != nil {
+		return err
+	}
+	return s.Store.Set(ctx, envelope)
+}
+
+// envelope returns the set request storing the value along with a new etag.
+func (s *etagStore) envelope(req *contribState.SetRequest) (*contribState.SetRequest, error) {
+	value, err := encodeValue(req.Value, req.ContentType)
+	if err != nil {
+		return nil, err
+	}
+	etag, err := newETag()
+	if err != nil {
+		return nil, err
+	}
+	envelope := make([]byte, 0, len(etagEnvelopePrefix)+etagLength+len(value))
+	envelope = append(envelope, etagEnvelopePrefix...)
+	envelope = append(envelope, etag...)
+	envelope = append(envelope, value...)
+
+	envelopeReq := *req
+	envelopeReq.Value = envelope
+	envelopeReq.ETag = nil
+	envelopeReq.Options.Concurrency = ""
+	return &envelopeReq, nil
+}
+
+func (s *etagStore) Delete(ctx context.Context, req *contribState.DeleteRequest) error {
+	unlock, err := s.locker.Lock(ctx, req.Key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.check(ctx, req.Key, req.ETag, req.Options.Concurrency, false); err != nil {
+		return err
+	}
+
+	deleteReq := *req
+	deleteReq.ETag = nil
+	deleteReq.Options.Concurrency = ""
+	return s.Store.Delete(ctx, &deleteReq)
+}
+
+// BulkSet sets each item through Set, so the etag errors are reported for each key.
+func (s *etagStore) BulkSet(ctx context.Context, req []contribState.SetRequest, opts contribState.BulkStoreOpts) error {
+	return contribState.DoBulkSetDelete(ctx, req, recoverWrite(s.Set), opts)
+}
+
+// BulkDelete deletes each item through Delete, so the etag errors are reported for each key.
+func (s *etagStore) BulkDelete(ctx context.Context, req []contribState.DeleteRequest, opts contribState.BulkStoreOpts) error {
+	return contribState.DoBulkSetDelete(ctx, req, recoverWrite(s.Delete), opts)
+}
+
+// transactional forwards the transactions to the underlying store once the etags of their operations are checked,
+// storing the values of their set operations along with a new etag.
+func (s *etagStore) transactional() (contribState.TransactionalStore, bool) {
+	inner, ok := transactional(s.Store)
+	if !ok {
+		return nil, false
+	}
+	return multiFunc(func(ctx context.Context, req *contribState.TransactionalStateRequest) error {
+		unlock, err := s.locker.Lock(ctx, internal.Map(req.Operations, func(op contribState.TransactionalStateOperation) string {
+			return op.GetKey()
+		})...)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		operations, err := internal.MapErr(req.Operations, func(op contribState.TransactionalStateOperation) (contribState.TransactionalStateOperation, error) {
+			switch op := op.(type) {
+			case contribState.SetRequest:
+				if err := s.check(ctx, op.Key, op.ETag, op.Options.Concurrency, true); err != nil {
+					return nil, contribState.NewBulkStoreError(op.Key, err)
+				}
+				envelope, err := s.envelope(&op)
+				if err != nil {
+					return nil, err
+				}
+				return *envelope, nil
+			case contribState.DeleteRequest:
+				if err := s.check(ctx, op.Key, op.ETag, op.Options.Concurrency, false); err != nil {
+					return nil, contribState.NewBulkStoreError(op.Key, err)
+				}
+				op.ETag = nil
+				op.Options.Concurrency = ""
+				return op, nil
+			default:
+				return op, nil
+			}
+		})
+		if err != nil {
+			return err
+		}
+		return inner.Multi(ctx, &contribState.TransactionalStateRequest{
+			Operations: operations,
+			Metadata:   req.Metadata,
+		})
+	}), true
+}
+
+func (s *etagStore) Get(ctx context.Context, req *contribState.GetRequest) (*contribState.GetResponse, error) {
+	resp, err := s.Store.Get(ctx, req)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	resp.Data, resp.ETag = openETagEnvelope(resp.Data)
+	return resp, nil
+}
+
+func (s *etagStore) BulkGet(ctx context.Context, req []contribState.GetRequest, opts contribState.BulkGetOpts) ([]contribState.BulkGetResponse, error) {
+	items, err := s.Store.BulkGet(ctx, req, opts)
+	if err != nil {
+		return items, err
+	}
+	for idx, item := range items {
+		if item.Error == "" {
+			items[idx].Data, items[idx].ETag = openETagEnvelope(item.Data)
+		}
+	}
+	return items, nil
+}
+
+// querier evaluates the queries in memory over the items of the underlying store, along with their etag, when it implements Lister.
+// native queries of the underlying store are not served, since they run over the stored envelopes.
+func (s *etagStore) querier() (contribState.Querier, bool) {
+	if lister, ok := lister(s.Store); ok {
+		return &listQuerier{lister: &etagLister{lister: lister}}, true
+	}
+	return nil, false
+}
+
+// check checks the etag and concurrency of a write against the current etag of the item.
+func (s *etagStore) check(ctx context.Context, key string, etag *string, concurrency string, isSet bool) error {
+	firstWrite := concurrency == contribState.FirstWrite
+	if etag == nil && !(firstWrite && isSet) {
+		return nil
+	}
+	if etag != nil && len(*etag) != etagLength {
+		return contribState.NewETagError(contribState.ETagInvalid, fmt.Errorf("invalid etag %q", *etag))
+	}
+
+	resp, err := s.Store.Get(ctx, &contribState.GetRequest{Key: key})
+	if err != nil {
+		return err
+	}
+	var current *string
+	exists := resp != nil && resp.Data != nil
+	if exists {
+		_, current = openETagEnvelope(resp.Data)
+	}
+
+	switch {
+	case etag == nil && exists:
+		return contribState.NewETagError(contribState.ETagMismatch, fmt.Errorf("key %s already exists", key))
+	case etag != nil && (current == nil || *current != *etag):
+		return contribState.NewETagError(contribState.ETagMismatch, fmt.Errorf("etag %s does not match", *etag))
+	default:
+		return nil
+	}
+}
+
+// newETag generates a new random etag.
+func newETag() ([]byte, error) {
+	b := make([]byte, etagLength/2)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	etag := make([]byte, etagLength)
+	hex.Encode(etag, b)
+	return etag, nil
+}
+
+// openETagEnvelope returns the value of a stored envelope along with its etag,
+// values that are not envelopes are returned as is without etag.
+func openETagEnvelope(data []byte) ([]byte, *string) {
+	if !bytes.HasPrefix(data, etagEnvelopePrefix) || len(data) < len(etagEnvelopePrefix)+etagLength {
+		return data, nil
+	}
+	etag := string(data[len(etagEnvelopePrefix) : len(etagEnvelopePrefix)+etagLength])
+	return data[len(etagEnvelopePrefix)+etagLength:], &etag
+}
+
+// etagLister lists the items of the underlying store along with their etag.
+type etagLister struct {
+	lister Lister
+}
+
+func (l *etagLister) List(ctx context.Context, yield func(ListItem) bool) error {
+	return l.lister.List(ctx, func(item ListItem) bool {
+		item.Value, item.ETag = openETagEnvelope(item.Value)
+		return yield(item)
+	})
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	contribState "github.com/dapr/components-contrib/state"
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeNoETagStore is a fakeMemListerStore that does not support etags.
+type fakeNoETagStore struct {
+	*fakeMemListerStore
+}
+
+func (f *fakeNoETagStore) Features() []contribState.Feature {
+	return nil
+}
+
+// fakeNoETagTransactionalStore is a fakeTransactionalStore that does not support etags.
+type fakeNoETagTransactionalStore struct {
+	*fakeTransactionalStore
+}
+
+func (f *fakeNoETagTransactionalStore) Features() []contribState.Feature {
+	return []contribState.Feature{contribState.FeatureTransactional}
+}
+
+func newETagStore() (Store, *fakeMemStore) {
+	mem := newFakeMemStore(nil)
+	return EmulateETags(&fakeNoETagStore{fakeMemListerStore: &fakeMemListerStore{fakeMemStore: mem}}), mem
+}
+
+func etagErrorKind(err error) contribState.ETagErrorKind {
+	var etagErr *contribState.ETagError
+	if !errors.As(err, &etagErr) {
+		return ""
+	}
+	return etagErr.Kind()
+}
+
+func TestEmulateETags(t *testing.T) {
+	ctx := context.Background()
+	get := func(t *testing.T, store Store, key string) *contribState.GetResponse {
+		resp, err := store.Get(ctx, &contribState.GetRequest{Key: key})
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("stores that support etags should be returned as is", func(t *testing.T) {
+		mem := newFakeMemStore(nil)
+		assert.Same(t, mem, EmulateETags(mem))
+	})
+
+	t.Run("transactions of a transactional store should be checked and forwarded", func(t *testing.T) {
+		tx := &fakeTransactionalStore{fakeMemStore: newFakeMemStore(nil)}
+		store := EmulateETags(&fakeNoETagTransactionalStore{fakeTransactionalStore: tx})
+		require.NoError(t, store.Set(ctx, &contribState.SetRequest{Key: "a", Value: []byte("1")}))
+		etag := get(t, store, "a").ETag
+		multi, ok := transactional(store)
+		require.True(t, ok)
+
+		stale := "0123456789abcdef"
+		err := multi.Multi(ctx, &contribState.TransactionalStateRequest{Operations: []contribState.TransactionalStateOperation{
+			contribState.SetRequest{Key: "b", Value: []byte("2")},
+			contribState.DeleteRequest{Key: "a", ETag: &stale},
+		}})
+		assert.Equal(t, contribState.ETagMismatch, etagErrorKind(err))
+		assert.Equal(t, int64(0), tx.multiCalled.Load())
+
+		require.NoError(t, multi.Multi(ctx, &contribState.TransactionalStateRequest{Operations: []contribState.TransactionalStateOperation{
+			contribState.SetRequest{Key: "a", Value: []byte("3"), ETag: etag},
+			contribState.SetRequest{Key: "b", Value: []byte("2")},
+		}}))
+		assert.Equal(t, int64(1), tx.multiCalled.Load())
+		resp := get(t, store, "a")
+		assert.Equal(t, []byte("3"), resp.Data)
+		assert.NotEqual(t, etag, resp.ETag)
+		assert.NotNil(t, get(t, store, "b").ETag)
+	})
+
+	t.Run("panics of the bulk operations should be reported as internal errors", func(t *testing.T) {
+		store := EmulateETags(&fakePanicStore{})
+		err := store.BulkSet(ctx, []contribState.SetRequest{{Key: "a", Value: []byte("1")}}, contribState.BulkStoreOpts{})
+		require.Len(t, bulkStoreErrors(err), 1)
+		assert.Equal(t, codes.Internal, status.Code(errors.Unwrap(bulkStoreErrors(err)[0])))
+
+		err = store.BulkDelete(ctx, []contribState.DeleteRequest{{Key: "a"}}, contribState.BulkStoreOpts{})
+		require.Len(t, bulkStoreErrors(err), 1)
+		assert.Equal(t, codes.Internal, status.Code(errors.Unwrap(bulkStoreErrors(err)[0])))
+	})
+
+	t.Run("native queries should not be served", func(t *testing.T) {
+		store := EmulateETags(&fakeQuerierStore{fakeMemStore: newFakeMemStore(nil)})
+		_, ok := queryable(store)
+		assert.False(t, ok)
+		assert.Equal(t, []contribState.Feature{contribState.FeatureETag}, store.Features())
+	})
+
+	t.Run("features should include etag", func(t *testing.T) {
+		store, _ := newETagStore()
+		assert.Equal(t, []contribState.Feature{contribState.FeatureETag}, store.Features())
+	})
+
+	t.Run("each set should generate a new etag", func(t *testing.T) {
+		store, mem := newETagStore()
+		require.NoError(t, store.Set(ctx, &contribState.SetRequest{Key: "a", Value: []byte("1")}))
+		first := get(t, store, "a")
+		assert.Equal(t, []byte("1"), first.Data)
+		require.NotNil(t, first.ETag)
+		assert.NotEqual(t, []byte("1"), mem.snapshot()["a"])
+
+		require.NoError(t, store.Set(ctx, &contribState.SetRequest{Key: "a", Value: []byte("2"), ETag: first.ETag}))
+		second := get(t, store, "a")
+		assert.Equal(t, []byte("2"), second.Data)
+		assert.NotEqual(t, *first.ETag, *second.ETag)
+	})
+
+	t.Run("writes with a stale etag should return a mismatch error", func(t *testing.T) {
+		store, _ := newETagStore()
+		require.NoError(t, store.Set(ctx, &contribState.SetRequest{Key: "a", Value: []byte("1")}))
+		stale := get(t, store, "a").ETag
+		require.NoError(t, store.Set(ctx, &contribState.SetRequest{Key: "a", Value: []byte("2")}))
+
+		err := store.Set(ctx, &contribState.SetRequest{Key: "a", Value: []byte("3"), ETag: stale})
+		assert.Equal(t, contribState.ETagMismatch, etagErrorKind(err))
+		err = store.Delete(ctx, &contribState.DeleteRequest{Key: "a", ETag: stale})
+		assert.Equal(t, contribState.ETagMismatch, etagErrorKind(err))
+		assert.Equal(t, []byte("2"), get(t, store, "a").Data)
+
+		require.NoError(t, store.Delete(ctx, &contribState.DeleteRequest{Key: "a", ETag: get(t, store, "a").ETag}))
+		assert.Nil(t, get(t, store, "a").Data)
+	})
+
+	t.Run("writes with an etag to missing or legacy items should return a mismatch error", func(t *testing.T) {
+		store, mem := newETagStore()
+		mem.items["legacy"] = []byte("1")
+		etag := "0123456789abcdef"
+		for _, key := range []string{"missing", "legacy"} {
+			err := store.Set(ctx, &contribState.SetRequest{Key: key, Value: []byte("2"), ETag: &etag})
+			assert.Equal(t, contribState.ETagMismatch, etagErrorKind(err))
+		}
+		assert.Nil(t, get(t, store, "legacy").ETag)
+	})
+
+	t.Run("malformed etags should return an invalid error", func(t *testing.T) {
+		store, _ := newETagStore()
+		etag := "1"
+		err := store.Set(ctx, &contribState.SetRequest{Key: "a", Value: []byte("1"), ETag: &etag})
+		assert.Equal(t, contribState.ETagInvalid, etagErrorKind(err))
+	})
+
+	t.Run("first write without etag should only create items", func(t *testing.T) {
+		store, _ := newETagStore()
+		firstWrite := contribState.SetStateOption{Concurrency: contribState.FirstWrite}
+		require.NoError(t, store.Set(ctx, &contribState.SetRequest{Key: "a", Value: []byte("1"), Options: firstWrite}))
+		err := store.Set(ctx, &contribState.SetRequest{Key: "a", Value: []byte("2"), Options: firstWrite})
+		assert.Equal(t, contribState.ETagMismatch, etagErrorKind(err))
+		assert.Equal(t, []byte("1"), get(t, store, "a").Data)
+	})
+
+	t.Run("concurrent writes with the same etag should only succeed once", func(t *testing.T) {
+		store, _ := newETagStore()
+		require.NoError(t, store.Set(ctx, &contribState.SetRequest{Key: "a", Value: []byte("0")}))
+		etag := get(t, store, "a").ETag
+
+		var succeeded atomic.Int64
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if store.Set(ctx, &contribState.SetRequest{Key: "a", Value: []byte("1"), ETag: etag}) == nil {
+					succeeded.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int64(1), succeeded.Load())
+	})
+
+	t.Run("bulk operations should return the etags and report the mismatched keys", func(t *testing.T) {
+		store, _ := newETagStore()
+		require.NoError(t, store.BulkSet(ctx, []contribState.SetRequest{{Key: "a", Value: []byte("1")}, {Key: "b", Value: []byte("2")}}, contribState.BulkStoreOpts{}))
+
+		items, err := store.BulkGet(ctx, []contribState.GetRequest{{Key: "a"}, {Key: "b"}}, contribState.BulkGetOpts{})
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		assert.Equal(t, []byte("1"), []byte(items[0].Data))
+		require.NotNil(t, items[0].ETag)
+		require.NotNil(t, items[1].ETag)
+
+		stale := "0123456789abcdef"
+		err = store.BulkSet(ctx, []contribState.SetRequest{
+			{Key: "a", Value: []byte("3"), ETag: items[0].ETag},
+			{Key: "b", Value: []byte("4"), ETag: &stale},
+		}, contribState.BulkStoreOpts{})
+		require.Len(t, bulkStoreErrors(err), 1)
+		assert.Equal(t, "b", bulkStoreErrors(err)[0].Key())
+		assert.Equal(t, contribState.ETagMismatch, etagErrorKind(err))
+		assert.Equal(t, []byte("3"), get(t, store, "a").Data)
+	})
+
+	t.Run("queries should return the etags of the items", func(t *testing.T) {
+		etags, _ := newETagStore()
+		require.NoError(t, etags.Set(ctx, &contribState.SetRequest{Key: "a", Value: []byte(`{"n":1}`)}))
+
+		s := &store{getInstance: func(context.Context) (Store, error) { return etags, nil }}
+		resp, err := s.Query(ctx, &proto.QueryRequest{Query: &proto.Query{}})
+		require.NoError(t, err)
+		require.Len(t, resp.Items, 1)
+		assert.Equal(t, []byte(`{"n":1}`), resp.Items[0].Data)
+		assert.Equal(t, *get(t, etags, "a").ETag, resp.Items[0].Etag.GetValue())
+	})
+}
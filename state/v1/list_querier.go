@@ -0,0 +1,227 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	contribState "github.com/dapr/components-contrib/state"
+	contribQuery "github.com/dapr/components-contrib/state/query"
+
+	sdkerrors "github.com/dapr-sandbox/components-go-sdk/errors"
+)
+
+// listQuerier evaluates queries in memory over the items of a Lister.
+type listQuerier struct {
+	lister Lister
+}
+
+// listedItem is a listed item along with its decoded json value.
+type listedItem struct {
+	ListItem
+	value any
+}
+
+func (q *listQuerier) Query(ctx context.Context, req *contribState.QueryRequest) (*contribState.QueryResponse, error) {
+	offset, err := decodeQueryToken(req.Query.Page.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []listedItem
+	err = q.lister.List(ctx, func(item ListItem) bool {
+		var value any
+		// items that are not json can't be queried.
+		if json.Unmarshal(item.Value, &value) != nil {
+			return true
+		}
+		if matchesFilter(req.Query.Filter, value) {
+			matches = append(matches, listedItem{ListItem: item, value: value})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// items are sorted by key first so pages are stable across queries.
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Key < matches[j].Key
+	})
+	sort.SliceStable(matches, func(i, j int) bool {
+		for _, s := range req.Query.Sort {
+			cmp := compareValues(valueAt(matches[i].value, s.Key), valueAt(matches[j].value, s.Key))
+			if cmp == 0 {
+				continue
+			}
+			if s.Order == contribQuery.DESC {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+
+	if offset > len(matches) {
+		offset = len(matches)
+	}
+	page := matches[offset:]
+	var token string
+	if limit := req.Query.Page.Limit; limit > 0 && limit < len(page) {
+		page = page[:limit]
+		token = encodeQueryToken(offset + limit)
+	}
+
+	results := make([]contribState.QueryItem, len(page))
+	for idx, item := range page {
+		results[idx] = contribState.QueryItem{
+			Key:         item.Key,
+			Data:        item.Value,
+			ETag:        item.ETag,
+			ContentType: item.ContentType,
+		}
+	}
+	return &contribState.QueryResponse{
+		Results: results,
+		Token:   token,
+	}, nil
+}
+
+// encodeQueryToken returns the opaque continuation token of the given offset.
+func encodeQueryToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+// decodeQueryToken returns the offset of the given continuation token, zero when there is none.
+func decodeQueryToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, sdkerrors.InvalidArgument("invalid query token %q", token)
+	}
+	offset, err := strconv.Atoi(string(decoded))
+	if err != nil || offset < 0 {
+		return 0, sdkerrors.InvalidArgument("invalid query token %q", token)
+	}
+	return offset, nil
+}
+
+// matchesFilter returns true when the value matches the filter, values always match an empty filter.
+func matchesFilter(filter contribQuery.Filter, value any) bool {
+	switch f := filter.(type) {
+	case nil:
+		return true
+	case *contribQuery.EQ:
+		return reflect.DeepEqual(valueAt(value, f.Key), f.Val)
+	case *contribQuery.IN:
+		fieldValue := valueAt(value, f.Key)
+		for _, v := range f.Vals {
+			if reflect.DeepEqual(fieldValue, v) {
+				return true
+			}
+		}
+		return false
+	case *contribQuery.AND:
+		for _, child := range f.Filters {
+			if !matchesFilter(child, value) {
+				return false
+			}
+		}
+		return true
+	case *contribQuery.OR:
+		for _, child := range f.Filters {
+			if matchesFilter(child, value) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// valueAt returns the value of the given dot separated path, nil when it does not exist.
+func valueAt(value any, path string) any {
+	for _, field := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		if value, ok = object[field]; !ok {
+			return nil
+		}
+	}
+	return value
+}
+
+// typeRank orders the values of different json types when sorting.
+func typeRank(value any) int {
+	switch value.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case float64:
+		return 2
+	case string:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// compareValues compares json values, values of different types are ordered by type
+// and arrays and objects are compared by their string representation.
+func compareValues(a, b any) int {
+	if rankA, rankB := typeRank(a), typeRank(b); rankA != rankB {
+		return rankA - rankB
+	}
+	switch va := a.(type) {
+	case nil:
+		return 0
+	case bool:
+		vb := b.(bool)
+		switch {
+		case va == vb:
+			return 0
+		case vb:
+			return -1
+		default:
+			return 1
+		}
+	case float64:
+		vb := b.(float64)
+		switch {
+		case va < vb:
+			return -1
+		case va > vb:
+			return 1
+		default:
+			return 0
+		}
+	case string:
+		return strings.Compare(va, b.(string))
+	default:
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+}
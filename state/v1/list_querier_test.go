@@ -0,0 +1,147 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	contribState "github.com/dapr/components-contrib/state"
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeListerStore struct {
+	Store
+	items   []ListItem
+	listErr error
+}
+
+func (f *fakeListerStore) Features() []contribState.Feature {
+	return []contribState.Feature{contribState.FeatureETag}
+}
+
+func (f *fakeListerStore) List(_ context.Context, yield func(ListItem) bool) error {
+	for _, item := range f.items {
+		if !yield(item) {
+			break
+		}
+	}
+	return f.listErr
+}
+
+// queryKeys returns the keys of the query response items.
+func queryKeys(resp *proto.QueryResponse) []string {
+	keys := make([]string, len(resp.Items))
+	for idx, item := range resp.Items {
+		keys[idx] = item.Key
+	}
+	return keys
+}
+
+//nolint:nosnakecase
+func TestListQuerier(t *testing.T) {
+	lister := &fakeListerStore{
+		items: []ListItem{
+			{Key: "d", Value: []byte(`{"person":{"org":"Dev Ops","id":1036},"city":"Seattle","state":"WA"}`), ETag: ptr("1")},
+			{Key: "a", Value: []byte(`{"person":{"org":"Hardware","id":1028},"city":"Portland","state":"OR"}`)},
+			{Key: "c", Value: []byte(`{"person":{"org":"Finance","id":1071},"city":"Sacramento","state":"CA"}`)},
+			{Key: "b", Value: []byte(`{"person":{"org":"Dev Ops","id":1042},"city":"Spokane","state":"WA"}`)},
+			{Key: "e", Value: []byte(`not json`)},
+		},
+	}
+	s := &store{getInstance: func(context.Context) (Store, error) { return lister, nil }}
+
+	t.Run("features should include the query api", func(t *testing.T) {
+		resp, err := s.Features(context.Background(), &proto.FeaturesRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{string(contribState.FeatureETag), string(contribState.FeatureQueryAPI)}, resp.Features)
+	})
+
+	t.Run("queries without filter should return all json items sorted by key", func(t *testing.T) {
+		resp, err := s.Query(context.Background(), &proto.QueryRequest{Query: &proto.Query{}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c", "d"}, queryKeys(resp))
+		assert.Equal(t, "1", resp.Items[3].Etag.Value)
+		assert.Empty(t, resp.Token)
+	})
+
+	t.Run("filters should be evaluated on nested paths", func(t *testing.T) {
+		resp, err := s.Query(context.Background(), &proto.QueryRequest{Query: &proto.Query{
+			Filter: queryFilter("OR", `[{"AND":[{"EQ":{"person.org":"Dev Ops"}},{"EQ":{"state":"WA"}}]},{"IN":{"person.id":[1071,2000]}}]`),
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b", "c", "d"}, queryKeys(resp))
+	})
+
+	t.Run("items should be sorted by each sorting key in order", func(t *testing.T) {
+		resp, err := s.Query(context.Background(), &proto.QueryRequest{Query: &proto.Query{
+			Sort: []*proto.Sorting{{Key: "state", Order: proto.Sorting_DESC}, {Key: "person.id"}},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"d", "b", "a", "c"}, queryKeys(resp))
+	})
+
+	t.Run("pages should be continued using the returned token", func(t *testing.T) {
+		query := &proto.Query{
+			Sort:       []*proto.Sorting{{Key: "person.id"}},
+			Pagination: &proto.Pagination{Limit: 3},
+		}
+		resp, err := s.Query(context.Background(), &proto.QueryRequest{Query: query})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "d", "b"}, queryKeys(resp))
+		require.NotEmpty(t, resp.Token)
+
+		query.Pagination.Token = resp.Token
+		resp, err = s.Query(context.Background(), &proto.QueryRequest{Query: query})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c"}, queryKeys(resp))
+		assert.Empty(t, resp.Token)
+	})
+
+	t.Run("invalid tokens should return an invalid argument error", func(t *testing.T) {
+		_, err := s.Query(context.Background(), &proto.QueryRequest{Query: &proto.Query{
+			Pagination: &proto.Pagination{Token: "not-a-token"},
+		}})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("list errors should be returned", func(t *testing.T) {
+		failing := &fakeListerStore{listErr: errors.New("fake-list-err")}
+		s := &store{getInstance: func(context.Context) (Store, error) { return failing, nil }}
+		_, err := s.Query(context.Background(), &proto.QueryRequest{Query: &proto.Query{}})
+		assert.ErrorContains(t, err, "fake-list-err")
+	})
+}
+
+func TestCompareValues(t *testing.T) {
+	t.Run("values of the same type should be compared by value", func(t *testing.T) {
+		assert.Negative(t, compareValues(float64(1), float64(2)))
+		assert.Positive(t, compareValues("b", "a"))
+		assert.Negative(t, compareValues(false, true))
+		assert.Zero(t, compareValues(nil, nil))
+	})
+
+	t.Run("values of different types should be ordered by type", func(t *testing.T) {
+		assert.Negative(t, compareValues(nil, false))
+		assert.Negative(t, compareValues(true, float64(0)))
+		assert.Negative(t, compareValues(float64(10), "1"))
+		assert.Negative(t, compareValues("z", map[string]any{}))
+	})
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+// defaultBulkParallelism is the parallelism of the bulk requests that don't request one, unless configured otherwise.
+const defaultBulkParallelism = 10
+
+// Option configures how the state store is served.
+type Option func(*options)
+
+type options struct {
+	// rawValues passes the values to the store as they were received.
+	rawValues bool
+	// bulkParallelism is the parallelism of the bulk requests that don't request one, zero or less is unlimited.
+	bulkParallelism int
+	// maxBulkParallelism caps the parallelism of bulk requests, zero or less is no cap.
+	maxBulkParallelism int
+}
+
+func newOptions(opts ...Option) options {
+	o := options{
+		bulkParallelism: defaultBulkParallelism,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithRawValues passes the values to the store as raw bytes regardless of their content type,
+// instead of decoding them using the registered codecs.
+func WithRawValues() Option {
+	return func(o *options) {
+		o.rawValues = true
+	}
+}
+
+// WithBulkParallelism sets the parallelism of the bulk requests that don't request one through their options
+// or the parallelism metadata, 10 by default. Zero or less runs all of the request operations in parallel.
+func WithBulkParallelism(parallelism int) Option {
+	return func(o *options) {
+		o.bulkParallelism = parallelism
+	}
+}
+
+// WithMaxBulkParallelism caps the parallelism of bulk requests, including the requested ones.
+func WithMaxBulkParallelism(parallelism int) Option {
+	return func(o *options) {
+		o.maxBulkParallelism = parallelism
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+
+	contribQuery "github.com/dapr/components-contrib/state/query"
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+
+	sdkerrors "github.com/dapr-sandbox/components-go-sdk/errors"
+
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// filter operators supported by the contrib query filters.
+const (
+	filterEQ  = "EQ"
+	filterIN  = "IN"
+	filterAND = "AND"
+	filterOR  = "OR"
+)
+
+// ToQuery converts the proto query into the contrib one, building its filter tree.
+// malformed filters, sorting and pagination return an invalid argument error pointing at the offending path.
+func ToQuery(q *proto.Query) (*contribQuery.Query, error) {
+	filters, err := toQueryFilters(q.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+
+	var filter contribQuery.Filter
+	if len(filters) > 0 {
+		if filter, err = buildFilter(contribQuery.FILTER, filters); err != nil {
+			return nil, err
+		}
+	}
+
+	sort := make([]contribQuery.Sorting, len(q.GetSort()))
+	for idx, s := range q.GetSort() {
+		if sort[idx], err = toSorting(fmt.Sprintf("%s[%d]", contribQuery.SORT, idx), s); err != nil {
+			return nil, err
+		}
+	}
+
+	if limit := q.GetPagination().GetLimit(); limit < 0 {
+		return nil, invalidQueryError(contribQuery.PAGE+".limit", "must not be negative, got %d", limit)
+	}
+
+	return &contribQuery.Query{
+		QueryFields: contribQuery.QueryFields{
+			Filters: filters,
+			Sort:    sort,
+			Page: contribQuery.Pagination{
+				Limit: int(q.GetPagination().GetLimit()),
+				Token: q.GetPagination().GetToken(),
+			},
+		},
+		Filter: filter,
+	}, nil
+}
+
+// toQueryFilters decodes the json value of each top level filter.
+func toQueryFilters(filter map[string]*anypb.Any) (map[string]any, error) {
+	if len(filter) == 0 {
+		return nil, nil
+	}
+	filters := make(map[string]any, len(filter))
+	for operator, f := range filter {
+		var v any
+		if err := json.Unmarshal(f.GetValue(), &v); err != nil {
+			return nil, invalidQueryError(contribQuery.FILTER+"."+operator, "value is not valid json: %v", err)
+		}
+		filters[operator] = v
+	}
+	return filters, nil
+}
+
+// toSorting converts and validates the proto sorting.
+func toSorting(path string, s *proto.Sorting) (contribQuery.Sorting, error) {
+	if s.GetKey() == "" {
+		return contribQuery.Sorting{}, invalidQueryError(path+".key", "must not be empty")
+	}
+	order := s.GetOrder().String()
+	if order != contribQuery.ASC && order != contribQuery.DESC {
+		return contribQuery.Sorting{}, invalidQueryError(path+".order", "unsupported order %s", order)
+	}
+	return contribQuery.Sorting{Key: s.GetKey(), Order: order}, nil
+}
+
+// buildFilter builds the filter of the given filter unit, a map holding a single operator.
+func buildFilter(path string, obj any) (contribQuery.Filter, error) {
+	unit, ok := obj.(map[string]any)
+	if !ok {
+		return nil, invalidQueryError(path, "must be an object")
+	}
+	if len(unit) != 1 {
+		return nil, invalidQueryError(path, "must have a single operator, got %d", len(unit))
+	}
+
+	for operator, operand := range unit {
+		operandPath := path + "." + operator
+		switch operator {
+		case filterEQ:
+			key, value, err := filterKeyValue(operandPath, operand)
+			if err != nil {
+				return nil, err
+			}
+			return &contribQuery.EQ{Key: key, Val: value}, nil
+		case filterIN:
+			key, value, err := filterKeyValue(operandPath, operand)
+			if err != nil {
+				return nil, err
+			}
+			values, ok := value.([]any)
+			if !ok {
+				return nil, invalidQueryError(operandPath+"."+key, "must be an array")
+			}
+			return &contribQuery.IN{Key: key, Vals: values}, nil
+		case filterAND:
+			filters, err := buildFilters(operandPath, operand)
+			if err != nil {
+				return nil, err
+			}
+			return &contribQuery.AND{Filters: filters}, nil
+		case filterOR:
+			filters, err := buildFilters(operandPath, operand)
+			if err != nil {
+				return nil, err
+			}
+			return &contribQuery.OR{Filters: filters}, nil
+		default:
+			return nil, invalidQueryError(path, "unsupported operator %q", operator)
+		}
+	}
+	return nil, nil
+}
+
+// buildFilters builds the filters of the AND and OR operators.
+func buildFilters(path string, operand any) ([]contribQuery.Filter, error) {
+	entries, ok := operand.([]any)
+	if !ok {
+		return nil, invalidQueryError(path, "must be an array")
+	}
+	if len(entries) < 2 {
+		return nil, invalidQueryError(path, "must have at least two filters, got %d", len(entries))
+	}
+
+	filters := make([]contribQuery.Filter, len(entries))
+	for idx, entry := range entries {
+		var err error
+		if filters[idx], err = buildFilter(fmt.Sprintf("%s[%d]", path, idx), entry); err != nil {
+			return nil, err
+		}
+	}
+	return filters, nil
+}
+
+// filterKeyValue returns the single key/value pair of the EQ and IN operators.
+func filterKeyValue(path string, operand any) (string, any, error) {
+	m, ok := operand.(map[string]any)
+	if !ok {
+		return "", nil, invalidQueryError(path, "must be an object")
+	}
+	if len(m) != 1 {
+		return "", nil, invalidQueryError(path, "must have a single key, got %d", len(m))
+	}
+	for key, value := range m {
+		if key == "" {
+			return "", nil, invalidQueryError(path, "key must not be empty")
+		}
+		return key, value, nil
+	}
+	return "", nil, nil
+}
+
+// invalidQueryError returns the invalid argument error of the given query path.
+func invalidQueryError(path string, format string, args ...any) error {
+	return sdkerrors.InvalidArgument("invalid query %s: %s", path, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"encoding/json"
+	"testing"
+
+	contribQuery "github.com/dapr/components-contrib/state/query"
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+
+	sdkerrors "github.com/dapr-sandbox/components-go-sdk/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// queryFilter returns the proto filter of the given operator and json operand.
+func queryFilter(operator, operand string) map[string]*anypb.Any {
+	return map[string]*anypb.Any{operator: {Value: []byte(operand)}}
+}
+
+//nolint:nosnakecase
+func TestToQuery(t *testing.T) {
+	t.Run("filters sorting and pagination should be converted", func(t *testing.T) {
+		const operand = `[{"EQ":{"state":"CA"}},{"IN":{"person.org":["A","B"]}}]`
+		query, err := ToQuery(&proto.Query{
+			Filter:     queryFilter("AND", operand),
+			Sort:       []*proto.Sorting{{Key: "state", Order: proto.Sorting_DESC}, {Key: "person.id"}},
+			Pagination: &proto.Pagination{Limit: 3, Token: "token"},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, &contribQuery.AND{Filters: []contribQuery.Filter{
+			&contribQuery.EQ{Key: "state", Val: "CA"},
+			&contribQuery.IN{Key: "person.org", Vals: []any{"A", "B"}},
+		}}, query.Filter)
+		assert.Equal(t, []contribQuery.Sorting{{Key: "state", Order: "DESC"}, {Key: "person.id", Order: "ASC"}}, query.Sort)
+		assert.Equal(t, contribQuery.Pagination{Limit: 3, Token: "token"}, query.Page)
+
+		var expected any
+		require.NoError(t, json.Unmarshal([]byte(operand), &expected))
+		assert.Equal(t, map[string]any{"AND": expected}, query.Filters)
+	})
+
+	t.Run("filter tree should match the contrib parser", func(t *testing.T) {
+		const filter = `{"OR":[{"EQ":{"a":1}},{"AND":[{"EQ":{"b":"x"}},{"IN":{"c":[1,2]}}]}]}`
+		var contrib contribQuery.Query
+		require.NoError(t, json.Unmarshal([]byte(`{"filter":`+filter+`}`), &contrib))
+
+		query, err := ToQuery(&proto.Query{Filter: queryFilter("OR", `[{"EQ":{"a":1}},{"AND":[{"EQ":{"b":"x"}},{"IN":{"c":[1,2]}}]}]`)})
+		require.NoError(t, err)
+		assert.Equal(t, contrib.Filter, query.Filter)
+	})
+
+	t.Run("empty queries should have no filter", func(t *testing.T) {
+		query, err := ToQuery(nil)
+		require.NoError(t, err)
+		assert.Nil(t, query.Filter)
+		assert.Empty(t, query.Sort)
+	})
+
+	invalid := []struct {
+		name  string
+		query *proto.Query
+		path  string
+	}{
+		{
+			name:  "invalid json",
+			query: &proto.Query{Filter: queryFilter("EQ", `{"a":`)},
+			path:  "filter.EQ",
+		},
+		{
+			name:  "unsupported operator",
+			query: &proto.Query{Filter: queryFilter("AND", `[{"EQ":{"a":1}},{"GT":{"b":1}}]`)},
+			path:  "filter.AND[1]",
+		},
+		{
+			name:  "multiple operators",
+			query: &proto.Query{Filter: map[string]*anypb.Any{"EQ": {Value: []byte(`{"a":1}`)}, "IN": {Value: []byte(`{"b":[1]}`)}}},
+			path:  "filter",
+		},
+		{
+			name:  "equality with multiple keys",
+			query: &proto.Query{Filter: queryFilter("OR", `[{"EQ":{"a":1,"b":2}},{"EQ":{"c":1}}]`)},
+			path:  "filter.OR[0].EQ",
+		},
+		{
+			name:  "equality with an empty key",
+			query: &proto.Query{Filter: queryFilter("EQ", `{"":1}`)},
+			path:  "filter.EQ",
+		},
+		{
+			name:  "inclusion without an array",
+			query: &proto.Query{Filter: queryFilter("IN", `{"a":1}`)},
+			path:  "filter.IN.a",
+		},
+		{
+			name:  "conjunction with a single filter",
+			query: &proto.Query{Filter: queryFilter("AND", `[{"EQ":{"a":1}}]`)},
+			path:  "filter.AND",
+		},
+		{
+			name:  "disjunction without an array",
+			query: &proto.Query{Filter: queryFilter("OR", `{"EQ":{"a":1}}`)},
+			path:  "filter.OR",
+		},
+		{
+			name:  "sorting without key",
+			query: &proto.Query{Sort: []*proto.Sorting{{Key: "a"}, {}}},
+			path:  "sort[1].key",
+		},
+		{
+			name:  "unknown sorting order",
+			query: &proto.Query{Sort: []*proto.Sorting{{Key: "a", Order: proto.Sorting_Order(42)}}},
+			path:  "sort[0].order",
+		},
+		{
+			name:  "negative limit",
+			query: &proto.Query{Pagination: &proto.Pagination{Limit: -1}},
+			path:  "page.limit",
+		},
+	}
+
+	for _, tt := range invalid {
+		tt := tt
+		t.Run(tt.name+" should return an invalid argument error with its path", func(t *testing.T) {
+			_, err := ToQuery(tt.query)
+			require.Error(t, err)
+			assert.Equal(t, codes.InvalidArgument, status.Code(sdkerrors.ToGRPC(err)))
+			assert.Contains(t, err.Error(), "invalid query "+tt.path+":")
+		})
+	}
+}
@@ -14,6 +14,8 @@ limitations under the License.
 package state
 
 import (
+	"context"
+
 	contribState "github.com/dapr/components-contrib/state"
 )
 
@@ -32,3 +34,19 @@ type TransactionalStore interface {
 type Querier interface {
 	contribState.Querier
 }
+
+// ListItem is an item stored by a Lister.
+type ListItem struct {
+	Key         string
+	Value       []byte
+	ETag        *string
+	ContentType *string
+}
+
+// Lister is an optional interface for stores that can iterate over their items.
+// Queries sent to stores that implement it but not Querier are evaluated by the SDK in memory,
+// which is only suitable for small datasets since every query lists all items.
+type Lister interface {
+	// List calls yield for each stored item until it returns false.
+	List(ctx context.Context, yield func(ListItem) bool) error
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	contribState "github.com/dapr/components-contrib/state"
+	stateUtils "github.com/dapr/components-contrib/state/utils"
+
+	"github.com/dapr-sandbox/components-go-sdk/internal"
+)
+
+// FeatureEmulatedTransactional is reported along with the transactional feature by stores whose transactions are emulated by the SDK,
+// see EmulateTransactions for their limitations.
+const FeatureEmulatedTransactional contribState.Feature = "EMULATED_TRANSACTIONAL"
+
+// emulatedTransactionalStore emulates transactions on top of a store that does not support them.
+type emulatedTransactionalStore struct {
+	*wrappedStore
+	locker *internal.KeyLocker
+}
+
+// EmulateTransactions returns a store that supports transactions on top of the given one, which is returned as is when it already does,
+// as the stores wrapped by the SDK do when the underlying store supports them.
+// Transactions are applied one operation at a time while holding a lock on each of their keys, writes made through the returned store
+// wait for the transactions on the same keys. When an operation fails, the keys written by the previous ones are restored to their
+// value before the transaction, as raw bytes along with their remaining ttl. Transactions are best-effort: they are not isolated from readers nor from other writers
+// of the underlying store, ETags change when keys are restored and restoring can fail, in which case the returned error includes the
+// restore errors. The store reports FeatureEmulatedTransactional so those limitations can be detected.
+func EmulateTransactions(store Store) Store {
+	if _, ok := transactional(store); ok {
+		return store
+	}
+	return &emulatedTransactionalStore{
+		wrappedStore: &wrappedStore{Store: store},
+		locker:       internal.NewKeyLocker(),
+	}
+}
+
+func (s *emulatedTransactionalStore) Features() []contribState.Feature {
+	features := s.Store.Features()
+	if !contribState.FeatureTransactional.IsPresent(features) {
+		features = append(features, contribState.FeatureTransactional)
+	}
+	return append(features, FeatureEmulatedTransactional)
+}
+
+func (s *emulatedTransactionalStore) Set(ctx context.Context, req *contribState.SetRequest) error {
+	unlock, err := s.locker.Lock(ctx, req.Key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.Store.Set(ctx, req)
+}
+
+func (s *emulatedTransactionalStore) Delete(ctx context.Context, req *contribState.DeleteRequest) error {
+	unlock, err := s.locker.Lock(ctx, req.Key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.Store.Delete(ctx, req)
+}
+
+func (s *emulatedTransactionalStore) BulkSet(ctx context.Context, req []contribState.SetRequest, opts contribState.BulkStoreOpts) error {
+	unlock, err := s.locker.Lock(ctx, internal.Map(req, func(r contribState.SetRequest) string { return r.Key })...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.Store.BulkSet(ctx, req, opts)
+}
+
+func (s *emulatedTransactionalStore) BulkDelete(ctx context.Context, req []contribState.DeleteRequest, opts contribState.BulkStoreOpts) error {
+	unlock, err := s.locker.Lock(ctx, internal.Map(req, func(r contribState.DeleteRequest) string { return r.Key })...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.Store.BulkDelete(ctx, req, opts)
+}
+
+// snapshot is the value of a key before the transaction, a nil response means the key did not exist.
+type snapshot struct {
+	key  string
+	resp *contribState.GetResponse
+}
+
+func (s *emulatedTransactionalStore) Multi(ctx context.Context, req *contribState.TransactionalStateRequest) error {
+	keys := internal.Map(req.Operations, func(op contribState.TransactionalStateOperation) string {
+		return op.GetKey()
+	})
+	unlock, err := s.locker.Lock(ctx, keys...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	snapshots := make(map[string]snapshot, len(keys))
+	for _, key := range keys {
+		if _, ok := snapshots[key]; ok {
+			continue
+		}
+		resp, err := s.Store.Get(ctx, &contribState.GetRequest{Key: key, Metadata: req.Metadata})
+		if err != nil {
+			return fmt.Errorf("could not snapshot key %s: %w", key, err)
+		}
+		if resp != nil && resp.Data == nil {
+			resp = nil
+		}
+		snapshots[key] = snapshot{key: key, resp: resp}
+	}
+
+	for idx, op := range req.Operations {
+		if err := s.apply(ctx, op); err != nil {
+			opErr := contribState.NewBulkStoreError(op.GetKey(), err)
+			return errors.Join(opErr, s.restore(ctx, req.Operations[:idx+1], snapshots))
+		}
+	}
+	return nil
+}
+
+// apply applies the given transactional operation.
+func (s *emulatedTransactionalStore) apply(ctx context.Context, op contribState.TransactionalStateOperation) error {
+	switch req := op.(type) {
+	case contribState.SetRequest:
+		return s.Store.Set(ctx, &req)
+	case contribState.DeleteRequest:
+		return s.Store.Delete(ctx, &req)
+	default:
+		return fmt.Errorf("unsupported transactional operation %s", op.Operation())
+	}
+}
+
+// restore restores the keys of the given operations to their snapshot, in reverse order.
+func (s *emulatedTransactionalStore) restore(ctx context.Context, ops []contribState.TransactionalStateOperation, snapshots map[string]snapshot) error {
+	var errs []error
+	restored := make(map[string]struct{}, len(ops))
+	for idx := len(ops) - 1; idx >= 0; idx-- {
+		key := ops[idx].GetKey()
+		if _, ok := restored[key]; ok {
+			continue
+		}
+		restored[key] = struct{}{}
+
+		var err error
+		if snap := snapshots[key]; snap.resp == nil {
+			err = s.Store.Delete(ctx, &contribState.DeleteRequest{Key: key})
+		} else {
+			err = s.Store.Set(ctx, &contribState.SetRequest{Key: key, Value: snap.resp.Data, ContentType: snap.resp.ContentType, Metadata: restoreMetadata(snap.resp)})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not restore key %s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// restoreMetadata returns the metadata that restores the remaining ttl of a snapshot, from its ttlExpireTime metadata.
+func restoreMetadata(resp *contribState.GetResponse) map[string]string {
+	expireTime, ok := resp.Metadata[contribState.GetRespMetaKeyTTLExpireTime]
+	if !ok {
+		return nil
+	}
+	expiry, err := time.Parse(time.RFC3339, expireTime)
+	if err != nil {
+		return nil
+	}
+	// the item expires at least a second later, since it did not expire when the snapshot was taken.
+	ttl := int64(math.Max(1, math.Ceil(time.Until(expiry).Seconds())))
+	return map[string]string{stateUtils.MetadataTTLKey: strconv.FormatInt(ttl, 10)}
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	contribState "github.com/dapr/components-contrib/state"
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeMemStore is an in memory store that stores values as bytes and can fail writes to some keys.
+type fakeMemStore struct {
+	Store
+	mu        sync.Mutex
+	items     map[string][]byte
+	failSetOn map[string]error
+}
+
+func newFakeMemStore(items map[string][]byte) *fakeMemStore {
+	if items == nil {
+		items = make(map[string][]byte)
+	}
+	return &fakeMemStore{items: items, failSetOn: make(map[string]error)}
+}
+
+func (f *fakeMemStore) Init(context.Context, contribState.Metadata) error {
+	return nil
+}
+
+func (f *fakeMemStore) Features() []contribState.Feature {
+	return []contribState.Feature{contribState.FeatureETag}
+}
+
+func (f *fakeMemStore) Get(_ context.Context, req *contribState.GetRequest) (*contribState.GetResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	value, ok := f.items[req.Key]
+	if !ok {
+		return &contribState.GetResponse{}, nil
+	}
+	return &contribState.GetResponse{Data: value}, nil
+}
+
+func (f *fakeMemStore) Set(_ context.Context, req *contribState.SetRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failSetOn[req.Key]; err != nil {
+		return err
+	}
+	value, ok := req.Value.([]byte)
+	if !ok {
+		value = []byte(req.Value.(string))
+	}
+	f.items[req.Key] = value
+	return nil
+}
+
+func (f *fakeMemStore) Delete(_ context.Context, req *contribState.DeleteRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.items, req.Key)
+	return nil
+}
+
+func (f *fakeMemStore) BulkGet(ctx context.Context, req []contribState.GetRequest, opts contribState.BulkGetOpts) ([]contribState.BulkGetResponse, error) {
+	return contribState.DoBulkGet(ctx, req, opts, f.Get)
+}
+
+func (f *fakeMemStore) BulkSet(ctx context.Context, req []contribState.SetRequest, opts contribState.BulkStoreOpts) error {
+	return contribState.DoBulkSetDelete(ctx, req, f.Set, opts)
+}
+
+func (f *fakeMemStore) BulkDelete(ctx context.Context, req []contribState.DeleteRequest, opts contribState.BulkStoreOpts) error {
+	return contribState.DoBulkSetDelete(ctx, req, f.Delete, opts)
+}
+
+func (f *fakeMemStore) snapshot() map[string][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	items := make(map[string][]byte, len(f.items))
+	for k, v := range f.items {
+		items[k] = v
+	}
+	return items
+}
+
+// fakeTransactionalStore is a fakeMemStore that applies the operations of its transactions and counts them.
+type fakeTransactionalStore struct {
+	*fakeMemStore
+	multiCalled atomic.Int64
+}
+
+func (f *fakeTransactionalStore) Multi(ctx context.Context, req *contribState.TransactionalStateRequest) error {
+	f.multiCalled.Add(1)
+	for _, op := range req.Operations {
+		switch op := op.(type) {
+		case contribState.SetRequest:
+			if err := f.Set(ctx, &op); err != nil {
+				return err
+			}
+		case contribState.DeleteRequest:
+			if err := f.Delete(ctx, &op); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestEmulateTransactions(t *testing.T) {
+	t.Run("transactional stores should be returned as is", func(t *testing.T) {
+		transactional := &fakeTransactionalStore{fakeMemStore: newFakeMemStore(nil)}
+		assert.Same(t, transactional, EmulateTransactions(transactional))
+	})
+
+	t.Run("stores wrapped on top of a transactional store should keep its transactions", func(t *testing.T) {
+		tx := &fakeTransactionalStore{fakeMemStore: newFakeMemStore(nil)}
+		wrapped := EmulateBulk(EmulateTTL(tx))
+		assert.Same(t, wrapped, EmulateTransactions(wrapped))
+
+		s := &store{getInstance: func(context.Context) (Store, error) { return wrapped, nil }}
+		_, err := s.Transact(context.Background(), &proto.TransactionalStateRequest{
+			Operations: []*proto.TransactionalStateOperation{
+				{Request: &proto.TransactionalStateOperation_Set{Set: &proto.SetRequest{Key: "a", Value: []byte("1"), Metadata: map[string]string{"ttlInSeconds": "10"}}}},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), tx.multiCalled.Load())
+
+		resp, err := wrapped.Get(context.Background(), &contribState.GetRequest{Key: "a"})
+		require.NoError(t, err)
+		assert.Equal(t, []byte("1"), resp.Data)
+		assert.Contains(t, resp.Metadata, contribState.GetRespMetaKeyTTLExpireTime)
+
+		_, ok := transactional(EmulateTTL(newFakeMemStore(nil)))
+		assert.False(t, ok)
+	})
+
+	t.Run("features should report emulated transactions", func(t *testing.T) {
+		store := EmulateTransactions(newFakeMemStore(nil))
+		assert.Equal(t, []contribState.Feature{contribState.FeatureETag, contribState.FeatureTransactional, FeatureEmulatedTransactional}, store.Features())
+	})
+
+	t.Run("operations should be applied in order", func(t *testing.T) {
+		mem := newFakeMemStore(map[string][]byte{"a": []byte("1"), "b": []byte("2")})
+		store := EmulateTransactions(mem).(contribState.TransactionalStore)
+
+		err := store.Multi(context.Background(), &contribState.TransactionalStateRequest{
+			Operations: []contribState.TransactionalStateOperation{
+				contribState.SetRequest{Key: "c", Value: "3"},
+				contribState.DeleteRequest{Key: "a"},
+				contribState.SetRequest{Key: "b", Value: "4"},
+				contribState.SetRequest{Key: "b", Value: "5"},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]byte{"b": []byte("5"), "c": []byte("3")}, mem.snapshot())
+	})
+
+	t.Run("keys written before a failed operation should be restored", func(t *testing.T) {
+		mem := newFakeMemStore(map[string][]byte{"a": []byte("1"), "b": []byte("2")})
+		fakeErr := errors.New("fake-set-err")
+		mem.failSetOn["d"] = fakeErr
+		store := EmulateTransactions(mem).(contribState.TransactionalStore)
+
+		err := store.Multi(context.Background(), &contribState.TransactionalStateRequest{
+			Operations: []contribState.TransactionalStateOperation{
+				contribState.SetRequest{Key: "c", Value: "3"},
+				contribState.DeleteRequest{Key: "a"},
+				contribState.SetRequest{Key: "d", Value: "4"},
+				contribState.SetRequest{Key: "b", Value: "5"},
+			},
+		})
+		assert.ErrorIs(t, err, fakeErr)
+		var bulkErr contribState.BulkStoreError
+		require.ErrorAs(t, err, &bulkErr)
+		assert.Equal(t, "d", bulkErr.Key())
+		assert.Equal(t, map[string][]byte{"a": []byte("1"), "b": []byte("2")}, mem.snapshot())
+	})
+
+	t.Run("restore errors should be returned along with the operation error", func(t *testing.T) {
+		mem := newFakeMemStore(map[string][]byte{"a": []byte("1")})
+		setErr, restoreErr := errors.New("fake-set-err"), errors.New("fake-restore-err")
+		mem.failSetOn["a"] = restoreErr
+		mem.failSetOn["b"] = setErr
+		store := EmulateTransactions(mem).(contribState.TransactionalStore)
+
+		err := store.Multi(context.Background(), &contribState.TransactionalStateRequest{
+			Operations: []contribState.TransactionalStateOperation{
+				contribState.DeleteRequest{Key: "a"},
+				contribState.SetRequest{Key: "b", Value: "2"},
+			},
+		})
+		assert.ErrorIs(t, err, setErr)
+		assert.ErrorIs(t, err, restoreErr)
+		assert.ErrorContains(t, err, "could not restore key a")
+	})
+
+	t.Run("concurrent transactions on the same keys should be serialized", func(t *testing.T) {
+		mem := newFakeMemStore(nil)
+		store := EmulateTransactions(mem).(contribState.TransactionalStore)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			value := string(rune('a' + i))
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, store.Multi(context.Background(), &contribState.TransactionalStateRequest{
+					Operations: []contribState.TransactionalStateOperation{
+						contribState.SetRequest{Key: "x", Value: value},
+						contribState.SetRequest{Key: "y", Value: value},
+					},
+				}))
+			}()
+		}
+		wg.Wait()
+		items := mem.snapshot()
+		assert.Equal(t, items["x"], items["y"])
+	})
+
+	t.Run("transact should be served through the emulated store", func(t *testing.T) {
+		mem := newFakeMemStore(nil)
+		emulated := EmulateTransactions(mem)
+		s := &store{getInstance: func(context.Context) (Store, error) { return emulated, nil }}
+
+		_, err := s.Transact(context.Background(), &proto.TransactionalStateRequest{
+			Operations: []*proto.TransactionalStateOperation{
+				{Request: &proto.TransactionalStateOperation_Set{Set: &proto.SetRequest{Key: "a", Value: []byte("1")}}},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]byte{"a": []byte("1")}, mem.snapshot())
+
+		_, err = (&store{getInstance: func(context.Context) (Store, error) { return mem, nil }}).Transact(context.Background(), &proto.TransactionalStateRequest{})
+		assert.Equal(t, codes.Unimplemented, status.Code(err))
+	})
+
+	t.Run("listers should still be queryable when wrapped", func(t *testing.T) {
+		_, ok := queryable(EmulateTransactions(&fakeListerStore{}))
+		assert.True(t, ok)
+	})
+}
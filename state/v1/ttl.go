@@ -0,0 +1,422 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	contribState "github.com/dapr/components-contrib/state"
+	stateUtils "github.com/dapr/components-contrib/state/utils"
+	"github.com/dapr/kit/logger"
+
+	sdkerrors "github.com/dapr-sandbox/components-go-sdk/errors"
+	"github.com/dapr-sandbox/components-go-sdk/internal"
+)
+
+// FeatureTTL is reported by stores that honor the ttlInSeconds metadata of set requests.
+const FeatureTTL contribState.Feature = "TTL"
+
+const (
+	// defaultTTLSweepInterval is the interval between the removals of expired items, unless configured otherwise.
+	defaultTTLSweepInterval = time.Minute
+	// defaultTTLSweepBatchSize is the maximum number of expired items removed at once, unless configured otherwise.
+	defaultTTLSweepBatchSize = 100
+)
+
+// ttlEnvelopePrefix marks the values stored along with their expiration time,
+// it is followed by the expiration time as big endian unix nanoseconds, zero when the value never expires.
+var ttlEnvelopePrefix = []byte("\x00dapr-ttl\x00")
+
+var ttlLogger = logger.NewLogger("state-component-ttl")
+
+// TTLOption configures the TTL emulation of EmulateTTL.
+type TTLOption func(*ttlStore)
+
+// WithTTLSweepInterval sets the interval between the removals of expired items, one minute by default.
+// Zero or less disables the removals, expired items are then hidden but never removed.
+func WithTTLSweepInterval(interval time.Duration) TTLOption {
+	return func(s *ttlStore) {
+		s.sweepInterval = interval
+	}
+}
+
+// WithTTLSweepBatchSize sets the maximum number of expired items removed at once, 100 by default.
+func WithTTLSweepBatchSize(size int) TTLOption {
+	return func(s *ttlStore) {
+		if size > 0 {
+			s.sweepBatchSize = size
+		}
+	}
+}
+
+// ttlStore emulates the ttl of the items on top of a store that does not support it.
+type ttlStore struct {
+	*wrappedStore
+	locker         *internal.KeyLocker
+	now            func() time.Time
+	sweepInterval  time.Duration
+	sweepBatchSize int
+
+	// sweeperMu guards the sweeper state, Init and Close can be called concurrently.
+	sweeperMu sync.Mutex
+	closed    bool
+	stop      context.CancelFunc
+	stopped   chan struct{}
+}
+
+// EmulateTTL returns a store that honors the ttlInSeconds metadata of set requests on top of the given one,
+// which is returned as is when it reports FeatureTTL. Values are stored along with their expiration time,
+// so the underlying store receives them as bytes, and expired items are hidden from Get, BulkGet and Query,
+// whose responses include the ttlExpireTime metadata of the items that expire.
+// When the underlying store implements Lister, expired items are periodically removed once it is initialized,
+// and queries are evaluated in memory over the items that did not expire. Native queries of the underlying store are not served,
+// since their filters would run over the stored envelopes.
+// Use it before EmulateTransactions so the transactional operations honor the ttl too, transactions of an underlying transactional
+// store are forwarded to it with the values of their set operations stored along with their expiration time.
+func EmulateTTL(store Store, opts ...TTLOption) Store {
+	if FeatureTTL.IsPresent(store.Features()) {
+		return store
+	}
+	s := &ttlStore{
+		wrappedStore:   &wrappedStore{Store: store},
+		locker:         internal.NewKeyLocker(),
+		now:            time.Now,
+		sweepInterval:  defaultTTLSweepInterval,
+		sweepBatchSize: defaultTTLSweepBatchSize,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *ttlStore) Features() []contribState.Feature {
+	return append(withoutNativeQueries(s.Store.Features()), FeatureTTL)
+}
+
+func (s *ttlStore) Init(ctx context.Context, metadata contribState.Metadata) error {
+	if err := s.Store.Init(ctx, metadata); err != nil {
+		return err
+	}
+	if lister, ok := lister(s.Store); ok && s.sweepInterval > 0 {
+		s.startSweeper(lister)
+	}
+	return nil
+}
+
+// Close stops removing the expired items and closes the underlying store.
+func (s *ttlStore) Close() error {
+	s.sweeperMu.Lock()
+	s.closed = true
+	stop, stopped := s.stop, s.stopped
+	s.sweeperMu.Unlock()
+
+	if stop != nil {
+		stop()
+		<-stopped
+	}
+	return s.wrappedStore.Close()
+}
+
+func (s *ttlStore) Set(ctx context.Context, req *contribState.SetRequest) error {
+	envelope, err := s.envelope(req)
+	if err != nil {
+		return err
+	}
+	unlock, err := s.locker.Lock(ctx, req.Key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.Store.Set(ctx, envelope)
+}
+
+func (s *ttlStore) BulkSet(ctx context.Context, req []contribState.SetRequest, opts contribState.BulkStoreOpts) error {
+	envelopes, err := internal.MapErr(req, func(r contribState.SetRequest) (contribState.SetRequest, error) {
+		envelope, err := s.envelope(&r)
+		if err != nil {
+			return contribState.SetRequest{}, err
+		}
+		return *envelope, nil
+	})
+	if err != nil {
+		return err
+	}
+	unlock, err := s.locker.Lock(ctx, internal.Map(req, func(r contribState.SetRequest) string { return r.Key })...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.Store.BulkSet(ctx, envelopes, opts)
+}
+
+// transactional forwards the transactions to the underlying store, storing the values of their set operations along with their expiration time.
+func (s *ttlStore) transactional() (contribState.TransactionalStore, bool) {
+	inner, ok := transactional(s.Store)
+	if !ok {
+		return nil, false
+	}
+	return multiFunc(func(ctx context.Context, req *contribState.TransactionalStateRequest) error {
+		operations, err := internal.MapErr(req.Operations, func(op contribState.TransactionalStateOperation) (contribState.TransactionalStateOperation, error) {
+			set, ok := op.(contribState.SetRequest)
+			if !ok {
+				return op, nil
+			}
+			envelope, err := s.envelope(&set)
+			if err != nil {
+				return nil, err
+			}
+			return *envelope, nil
+		})
+		if err != nil {
+			return err
+		}
+		unlock, err := s.locker.Lock(ctx, internal.Map(req.Operations, func(op contribState.TransactionalStateOperation) string {
+			return op.GetKey()
+		})...)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		return inner.Multi(ctx, &contribState.TransactionalStateRequest{
+			Operations: operations,
+			Metadata:   req.Metadata,
+		})
+	}), true
+}
+
+func (s *ttlStore) Delete(ctx context.Context, req *contribState.DeleteRequest) error {
+	unlock, err := s.locker.Lock(ctx, req.Key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.Store.Delete(ctx, req)
+}
+
+func (s *ttlStore) BulkDelete(ctx context.Context, req []contribState.DeleteRequest, opts contribState.BulkStoreOpts) error {
+	unlock, err := s.locker.Lock(ctx, internal.Map(req, func(r contribState.DeleteRequest) string { return r.Key })...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.Store.BulkDelete(ctx, req, opts)
+}
+
+func (s *ttlStore) Get(ctx context.Context, req *contribState.GetRequest) (*contribState.GetResponse, error) {
+	resp, err := s.Store.Get(ctx, req)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	data, expireTime, expired := s.open(resp.Data)
+	if expired {
+		return &contribState.GetResponse{}, nil
+	}
+	resp.Data = data
+	resp.Metadata = withExpireTime(resp.Metadata, expireTime)
+	return resp, nil
+}
+
+func (s *ttlStore) BulkGet(ctx context.Context, req []contribState.GetRequest, opts contribState.BulkGetOpts) ([]contribState.BulkGetResponse, error) {
+	items, err := s.Store.BulkGet(ctx, req, opts)
+	if err != nil {
+		return items, err
+	}
+	for idx, item := range items {
+		if item.Error != "" {
+			continue
+		}
+		data, expireTime, expired := s.open(item.Data)
+		if expired {
+			items[idx] = contribState.BulkGetResponse{Key: item.Key}
+			continue
+		}
+		items[idx].Data = data
+		items[idx].Metadata = withExpireTime(item.Metadata, expireTime)
+	}
+	return items, nil
+}
+
+// querier evaluates the queries in memory over the items that did not expire when the underlying store implements Lister.
+// native queries of the underlying store are not served, since they run over the stored envelopes.
+func (s *ttlStore) querier() (contribState.Querier, bool) {
+	if lister, ok := lister(s.Store); ok {
+		return &listQuerier{lister: &ttlLister{store: s, lister: lister}}, true
+	}
+	return nil, false
+}
+
+// envelope returns the set request storing the value along with its expiration time.
+func (s *ttlStore) envelope(req *contribState.SetRequest) (*contribState.SetRequest, error) {
+	ttl, err := stateUtils.ParseTTL(req.Metadata)
+	if err != nil {
+		return nil, sdkerrors.InvalidArgument("%w", err)
+	}
+	value, err := encodeValue(req.Value, req.ContentType)
+	if err != nil {
+		return nil, err
+	}
+
+	var expireTime int64
+	if ttl != nil && *ttl >= 0 {
+		expireTime = s.now().Add(time.Duration(*ttl) * time.Second).UnixNano()
+	}
+	envelope := make([]byte, len(ttlEnvelopePrefix)+8+len(value))
+	copy(envelope, ttlEnvelopePrefix)
+	binary.BigEndian.PutUint64(envelope[len(ttlEnvelopePrefix):], uint64(expireTime))
+	copy(envelope[len(ttlEnvelopePrefix)+8:], value)
+
+	metadata := make(map[string]string, len(req.Metadata))
+	for k, v := range req.Metadata {
+		if k != stateUtils.MetadataTTLKey {
+			metadata[k] = v
+		}
+	}
+
+	envelopeReq := *req
+	envelopeReq.Value = envelope
+	envelopeReq.Metadata = metadata
+	return &envelopeReq, nil
+}
+
+// open returns the value of a stored envelope along with its expiration time, if any, and whether it expired.
+// values that are not envelopes are returned as is.
+func (s *ttlStore) open(data []byte) (value []byte, expireTime *time.Time, expired bool) {
+	if !bytes.HasPrefix(data, ttlEnvelopePrefix) || len(data) < len(ttlEnvelopePrefix)+8 {
+		return data, nil, false
+	}
+	value = data[len(ttlEnvelopePrefix)+8:]
+	expireNanos := int64(binary.BigEndian.Uint64(data[len(ttlEnvelopePrefix):]))
+	if expireNanos == 0 {
+		return value, nil, false
+	}
+	expire := time.Unix(0, expireNanos)
+	return value, &expire, !s.now().Before(expire)
+}
+
+// withExpireTime returns the response metadata including the expiration time, if any.
+func withExpireTime(metadata map[string]string, expireTime *time.Time) map[string]string {
+	if expireTime == nil {
+		return metadata
+	}
+	if metadata == nil {
+		metadata = make(map[string]string, 1)
+	}
+	metadata[contribState.GetRespMetaKeyTTLExpireTime] = expireTime.UTC().Format(time.RFC3339)
+	return metadata
+}
+
+// startSweeper starts removing the expired items periodically until the store is closed,
+// it does nothing when the sweeper is already running or the store is closed.
+func (s *ttlStore) startSweeper(lister Lister) {
+	s.sweeperMu.Lock()
+	defer s.sweeperMu.Unlock()
+	if s.closed || s.stop != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	s.stop, s.stopped = cancel, stopped
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(s.sweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.sweep(ctx, lister); err != nil && ctx.Err() == nil {
+					ttlLogger.Warnf("could not remove the expired items: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// sweep removes the expired items in batches, the items are checked again while holding their lock
+// so the items set again since they were listed are kept.
+func (s *ttlStore) sweep(ctx context.Context, lister Lister) error {
+	var expired []string
+	err := lister.List(ctx, func(item ListItem) bool {
+		if _, _, isExpired := s.open(item.Value); isExpired {
+			expired = append(expired, item.Key)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for len(expired) > 0 {
+		batch := expired
+		if len(batch) > s.sweepBatchSize {
+			batch = batch[:s.sweepBatchSize]
+		}
+		expired = expired[len(batch):]
+		if err := s.sweepBatch(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sweepBatch removes the given keys that are still expired.
+func (s *ttlStore) sweepBatch(ctx context.Context, keys []string) error {
+	unlock, err := s.locker.Lock(ctx, keys...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	items, err := s.Store.BulkGet(ctx, internal.Map(keys, func(key string) contribState.GetRequest {
+		return contribState.GetRequest{Key: key}
+	}), contribState.BulkGetOpts{})
+	if err != nil {
+		return err
+	}
+
+	var deletes []contribState.DeleteRequest
+	for _, item := range items {
+		if _, _, isExpired := s.open(item.Data); isExpired && item.Error == "" {
+			deletes = append(deletes, contribState.DeleteRequest{Key: item.Key, ETag: item.ETag})
+		}
+	}
+	if len(deletes) == 0 {
+		return nil
+	}
+	return s.Store.BulkDelete(ctx, deletes, contribState.BulkStoreOpts{})
+}
+
+// ttlLister lists the items of the underlying store that did not expire.
+type ttlLister struct {
+	store  *ttlStore
+	lister Lister
+}
+
+func (l *ttlLister) List(ctx context.Context, yield func(ListItem) bool) error {
+	return l.lister.List(ctx, func(item ListItem) bool {
+		value, _, expired := l.store.open(item.Value)
+		if expired {
+			return true
+		}
+		item.Value = value
+		return yield(item)
+	})
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"testing"
+	"time"
+
+	contribState "github.com/dapr/components-contrib/state"
+	proto "github.com/dapr/dapr/pkg/proto/components/v1"
+
+	sdkerrors "github.com/dapr-sandbox/components-go-sdk/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeMemListerStore is a fakeMemStore that lists its items sorted by key.
+type fakeMemListerStore struct {
+	*fakeMemStore
+}
+
+func (f *fakeMemListerStore) List(_ context.Context, yield func(ListItem) bool) error {
+	items := f.snapshot()
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !yield(ListItem{Key: key, Value: items[key]}) {
+			break
+		}
+	}
+	return nil
+}
+
+type fakeTTLStore struct {
+	fakeMemStore
+}
+
+func (f *fakeTTLStore) Features() []contribState.Feature {
+	return []contribState.Feature{FeatureTTL}
+}
+
+// fakeQuerierStore is a fakeMemStore with native queries, which reports the query feature.
+type fakeQuerierStore struct {
+	*fakeMemStore
+}
+
+func (f *fakeQuerierStore) Features() []contribState.Feature {
+	return []contribState.Feature{contribState.FeatureQueryAPI}
+}
+
+func (f *fakeQuerierStore) Query(context.Context, *contribState.QueryRequest) (*contribState.QueryResponse, error) {
+	return &contribState.QueryResponse{}, nil
+}
+
+// newTTLStore returns a ttl store over an in memory lister whose clock is controlled by the returned function.
+func newTTLStore(opts ...TTLOption) (*ttlStore, *fakeMemListerStore, func(time.Duration)) {
+	mem := &fakeMemListerStore{fakeMemStore: newFakeMemStore(nil)}
+	store := EmulateTTL(mem, opts...).(*ttlStore)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	return store, mem, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestEmulateTTL(t *testing.T) {
+	t.Run("stores that support ttl should be returned as is", func(t *testing.T) {
+		ttl := &fakeTTLStore{}
+		assert.Same(t, ttl, EmulateTTL(ttl))
+	})
+
+	t.Run("features should include ttl", func(t *testing.T) {
+		store, _, _ := newTTLStore()
+		assert.Equal(t, []contribState.Feature{contribState.FeatureETag, FeatureTTL}, store.Features())
+	})
+
+	t.Run("expired items should be hidden and report their expire time until then", func(t *testing.T) {
+		store, mem, advance := newTTLStore()
+		ctx := context.Background()
+
+		require.NoError(t, store.Set(ctx, &contribState.SetRequest{Key: "a", Value: []byte("1"), Metadata: map[string]string{"ttlInSeconds": "10", "other": "x"}}))
+		require.NoError(t, store.Set(ctx, &contribState.SetRequest{Key: "b", Value: []byte("2")}))
+		assert.NotEqual(t, []byte("1"), mem.snapshot()["a"])
+
+		resp, err := store.Get(ctx, &contribState.GetRequest{Key: "a"})
+		require.NoError(t, err)
+		assert.Equal(t, []byte("1"), resp.Data)
+		assert.Equal(t, store.now().Add(10*time.Second).UTC().Format(time.RFC3339), resp.Metadata[contribState.GetRespMetaKeyTTLExpireTime])
+
+		resp, err = store.Get(ctx, &contribState.GetRequest{Key: "b"})
+		require.NoError(t, err)
+		assert.Equal(t, []byte("2"), resp.Data)
+		assert.NotContains(t, resp.Metadata, contribState.GetRespMetaKeyTTLExpireTime)
+
+		advance(10 * time.Second)
+		resp, err = store.Get(ctx, &contribState.GetRequest{Key: "a"})
+		require.NoError(t, err)
+		assert.Nil(t, resp.Data)
+
+		items, err := store.BulkGet(ctx, []contribState.GetRequest{{Key: "a"}, {Key: "b"}}, contribState.BulkGetOpts{})
+		require.NoError(t, err)
+		assert.Equal(t, contribState.BulkGetResponse{Key: "a"}, items[0])
+		assert.Equal(t, []byte("2"), []byte(items[1].Data))
+	})
+
+	t.Run("values that are not envelopes should be returned as is", func(t *testing.T) {
+		store, mem, _ := newTTLStore()
+		mem.items["a"] = []byte("legacy")
+		resp, err := store.Get(context.Background(), &contribState.GetRequest{Key: "a"})
+		require.NoError(t, err)
+		assert.Equal(t, []byte("legacy"), resp.Data)
+	})
+
+	t.Run("invalid ttl should return an invalid argument error", func(t *testing.T) {
+		store, _, _ := newTTLStore()
+		err := store.Set(context.Background(), &contribState.SetRequest{Key: "a", Value: []byte("1"), Metadata: map[string]string{"ttlInSeconds": "soon"}})
+		assert.Equal(t, codes.InvalidArgument, status.Code(sdkerrors.ToGRPC(err)))
+	})
+
+	t.Run("queries should not return expired items", func(t *testing.T) {
+		ttl, _, advance := newTTLStore()
+		ctx := context.Background()
+		require.NoError(t, ttl.Set(ctx, &contribState.SetRequest{Key: "a", Value: []byte(`{"n":1}`), Metadata: map[string]string{"ttlInSeconds": "1"}}))
+		require.NoError(t, ttl.Set(ctx, &contribState.SetRequest{Key: "b", Value: []byte(`{"n":2}`)}))
+		advance(time.Second)
+
+		s := &store{getInstance: func(context.Context) (Store, error) { return ttl, nil }}
+		resp, err := s.Query(ctx, &proto.QueryRequest{Query: &proto.Query{}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b"}, queryKeys(resp))
+		assert.Equal(t, []byte(`{"n":2}`), resp.Items[0].Data)
+	})
+
+	t.Run("native queries should not be served", func(t *testing.T) {
+		for name, wrapped := range map[string]Store{
+			"ttl": EmulateTTL(&fakeQuerierStore{fakeMemStore: newFakeMemStore(nil)}),
+		} {
+			_, ok := queryable(wrapped)
+			assert.False(t, ok, name)
+			assert.False(t, contribState.FeatureQueryAPI.IsPresent(wrapped.Features()), name)
+		}
+	})
+
+	t.Run("rolled back keys should keep their ttl", func(t *testing.T) {
+		ttl, mem, advance := newTTLStore()
+		ctx := context.Background()
+		require.NoError(t, ttl.Set(ctx, &contribState.SetRequest{Key: "a", Value: []byte("1"), Metadata: map[string]string{"ttlInSeconds": "10"}}))
+		mem.failSetOn["b"] = errors.New("fake-set-err")
+
+		err := EmulateTransactions(ttl).(contribState.TransactionalStore).Multi(ctx, &contribState.TransactionalStateRequest{
+			Operations: []contribState.TransactionalStateOperation{
+				contribState.SetRequest{Key: "a", Value: []byte("2")},
+				contribState.SetRequest{Key: "b", Value: []byte("3")},
+			},
+		})
+		require.Error(t, err)
+
+		resp, err := ttl.Get(ctx, &contribState.GetRequest{Key: "a"})
+		require.NoError(t, err)
+		assert.Equal(t, []byte("1"), resp.Data)
+		assert.Contains(t, resp.Metadata, contribState.GetRespMetaKeyTTLExpireTime)
+
+		advance(11 * time.Second)
+		resp, err = ttl.Get(ctx, &contribState.GetRequest{Key: "a"})
+		require.NoError(t, err)
+		assert.Nil(t, resp.Data)
+	})
+
+	t.Run("sweeper should remove the expired items in batches", func(t *testing.T) {
+		store, mem, advance := newTTLStore(WithTTLSweepBatchSize(2))
+		ctx := context.Background()
+		for _, key := range []string{"a", "b", "c"} {
+			require.NoError(t, store.Set(ctx, &contribState.SetRequest{Key: key, Value: []byte(key), Metadata: map[string]string{"ttlInSeconds": "1"}}))
+		}
+		require.NoError(t, store.Set(ctx, &contribState.SetRequest{Key: "d", Value: []byte("d")}))
+		advance(time.Second)
+
+		require.NoError(t, store.sweep(ctx, mem))
+		assert.Equal(t, []string{"d"}, keysOf(mem.snapshot()))
+	})
+
+	t.Run("sweeper should run until the store is closed", func(t *testing.T) {
+		mem := &fakeMemListerStore{fakeMemStore: newFakeMemStore(nil)}
+		store := EmulateTTL(mem, WithTTLSweepInterval(5*time.Millisecond))
+		ctx := context.Background()
+		require.NoError(t, store.Init(ctx, contribState.Metadata{}))
+		require.NoError(t, store.Set(ctx, &contribState.SetRequest{Key: "a", Value: []byte("a"), Metadata: map[string]string{"ttlInSeconds": "0"}}))
+
+		assert.Eventually(t, func() bool {
+			return len(mem.snapshot()) == 0
+		}, time.Second, 5*time.Millisecond)
+		assert.NoError(t, store.(io.Closer).Close())
+	})
+	t.Run("sweeper should not start once the store is closed", func(t *testing.T) {
+		mem := &fakeMemListerStore{fakeMemStore: newFakeMemStore(nil)}
+		store := EmulateTTL(mem, WithTTLSweepInterval(5*time.Millisecond)).(*ttlStore)
+		ctx := context.Background()
+
+		done := make(chan error, 1)
+		go func() {
+			done <- store.Init(ctx, contribState.Metadata{})
+		}()
+		assert.NoError(t, store.Close())
+		require.NoError(t, <-done)
+
+		require.NoError(t, store.Init(ctx, contribState.Metadata{}))
+		store.sweeperMu.Lock()
+		defer store.sweeperMu.Unlock()
+		if store.stopped != nil {
+			select {
+			case <-store.stopped:
+			default:
+				t.Fatal("sweeper should be stopped")
+			}
+		}
+	})
+}
+
+func keysOf(items map[string][]byte) []string {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"context"
+	"io"
+
+	contribState "github.com/dapr/components-contrib/state"
+
+	"github.com/dapr-sandbox/components-go-sdk/internal"
+)
+
+// wrappedStore is embedded by the stores that wrap another one to add a capability on top of it,
+// it delegates the optional interfaces that can't be promoted from the Store interface.
+type wrappedStore struct {
+	Store
+}
+
+// Unwrap returns the underlying store.
+func (s *wrappedStore) Unwrap() Store {
+	return s.Store
+}
+
+// Ping pings the underlying store.
+func (s *wrappedStore) Ping(ctx context.Context) error {
+	return internal.Ping(ctx, s.Store)
+}
+
+// Close closes the underlying store when it implements io.Closer.
+func (s *wrappedStore) Close() error {
+	if closer, ok := s.Store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// transactional forwards the transactions to the underlying store as is.
+func (s *wrappedStore) transactional() (contribState.TransactionalStore, bool) {
+	return transactional(s.Store)
+}
+
+// transactionalProvider is implemented by the stores wrapped by the SDK, whose transactions are forwarded to the underlying store.
+type transactionalProvider interface {
+	transactional() (contribState.TransactionalStore, bool)
+}
+
+// transactional returns the transactional store of the instance,
+// stores wrapped by the SDK are transactional when the underlying store is.
+func transactional(instance Store) (contribState.TransactionalStore, bool) {
+	if transactional, ok := instance.(contribState.TransactionalStore); ok && transactional != nil {
+		return transactional, true
+	}
+	if provider, ok := instance.(transactionalProvider); ok {
+		return provider.transactional()
+	}
+	return nil, false
+}
+
+// multiFunc is a function that implements the TransactionalStore interface.
+type multiFunc func(ctx context.Context, req *contribState.TransactionalStateRequest) error
+
+func (f multiFunc) Multi(ctx context.Context, req *contribState.TransactionalStateRequest) error {
+	return f(ctx, req)
+}
+
+// withoutNativeQueries removes the query feature of the underlying store, for the wrappers that can't serve its native queries.
+// the feature is reported again when the wrapper evaluates the queries in memory over a Lister.
+func withoutNativeQueries(features []contribState.Feature) []contribState.Feature {
+	filtered := make([]contribState.Feature, 0, len(features))
+	for _, feature := range features {
+		if feature != contribState.FeatureQueryAPI {
+			filtered = append(filtered, feature)
+		}
+	}
+	return filtered
+}